@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	neturl "net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+
+	"github.com/ozansz/homelab-functions/pkg/minioext"
+	"github.com/ozansz/homelab-functions/pkg/wordpress"
+	"github.com/ozansz/homelab-functions/pkg/wpaudit"
+)
+
+const (
+	minioAccessKeyIDEnv     = "MINIO_ACCESS_KEY_ID"
+	minioSecretAccessKeyEnv = "MINIO_SECRET_ACCESS_KEY"
+
+	reportJSONFile     = "report.json"
+	reportMarkdownFile = "report.md"
+	// The last report of a site, compared with the next one.
+	latestFile = "latest.json"
+
+	// Exit code of runs with new findings, to tell them from failed runs.
+	exitNewFindings = 2
+)
+
+var (
+	urls         = flag.String("urls", "", "Comma separated URLs of the WordPress sites to audit")
+	httpTimeout  = flag.Duration("http-timeout", 10*time.Second, "Timeout for HTTP requests")
+	rateLimit    = flag.Float64("rate-limit", 0, "Maximum requests per second sent to each site, 0 for no limit")
+	failSeverity = flag.String("fail-severity", string(wpaudit.SeverityInfo), "Exit with code 2 if a new finding of at least this severity shows up: info, low, medium or high")
+	prefix       = flag.String("prefix", "audit", "Path prefix of the reports in the bucket, followed by the host of the site")
+	layout       = flag.String("layout", string(minioext.LayoutYYYYMMDDHHMM), "Time layout of the report paths")
+
+	minioEndpoint    = flag.String("minio-endpoint", "", "Minio endpoint")
+	minioRegion      = flag.String("minio-region", "", "Minio region")
+	minioBucket      = flag.String("minio-bucket", "", "Minio bucket")
+	minioHTTPTimeout = flag.Duration("minio-http-timeout", 10*time.Second, "Timeout for Minio HTTP requests")
+
+	debugOutput = flag.Bool("debug-output", false, "Print the reports instead of uploading them, every finding is new")
+
+	minioAccessKeyID     string
+	minioSecretAccessKey string
+	minioCl              *minioext.Client
+)
+
+func main() {
+	flag.Parse()
+	minioAccessKeyID = os.Getenv(minioAccessKeyIDEnv)
+	minioSecretAccessKey = os.Getenv(minioSecretAccessKeyEnv)
+
+	minSeverity := mustValidateConfig()
+
+	ctx := context.Background()
+
+	if !*debugOutput {
+		var err error
+		minioCl, err = minioext.NewClient(*minioEndpoint, *minioRegion, minioext.WithTimeout(*minioHTTPTimeout), minioext.WithCredentials(minioAccessKeyID, minioSecretAccessKey))
+		if err != nil {
+			log.Fatalf("failed to create minio client: %v", err)
+		}
+	}
+
+	// A failing site must not keep the others from being audited.
+	failed, newFindings := 0, 0
+	for _, u := range strings.Split(*urls, ",") {
+		u = strings.TrimSpace(u)
+		findings, err := auditSite(ctx, u, minSeverity)
+		if err != nil {
+			failed++
+			log.Printf("%s: failed: %v", u, err)
+			continue
+		}
+		for _, f := range findings {
+			log.Printf("%s: new %s finding: %s", u, f.Severity, f.Title)
+		}
+		newFindings += len(findings)
+	}
+	if failed > 0 {
+		log.Fatalf("%d sites failed", failed)
+	}
+	if newFindings > 0 {
+		log.Printf("%d new findings", newFindings)
+		os.Exit(exitNewFindings)
+	}
+
+	log.Println("ok!")
+}
+
+// Audits the site, stores the report and returns the new findings of at
+// least the given severity.
+func auditSite(ctx context.Context, siteURL string, minSeverity wpaudit.Severity) ([]wpaudit.Finding, error) {
+	wpOpts := []wordpress.NewClientOpt{
+		wordpress.WithTimeout(*httpTimeout),
+	}
+	if *rateLimit > 0 {
+		wpOpts = append(wpOpts, wordpress.WithRateLimit(*rateLimit, 1))
+	}
+	report, err := wpaudit.Audit(ctx, wordpress.NewClient(siteURL, wpOpts...), siteURL)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := path.Join(*prefix, host(siteURL))
+	var prev *wpaudit.Report
+	if !*debugOutput {
+		if prev, err = loadReport(ctx, path.Join(dir, latestFile)); err != nil {
+			return nil, fmt.Errorf("failed to load the previous report: %w", err)
+		}
+	}
+	report.Compare(prev)
+
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal report: %w", err)
+	}
+	var md bytes.Buffer
+	if err := report.WriteMarkdown(&md); err != nil {
+		return nil, fmt.Errorf("failed to write report: %w", err)
+	}
+	if *debugOutput {
+		fmt.Println(md.String())
+		return report.NewFindings(minSeverity), nil
+	}
+
+	reportDir := path.Join(dir, report.CreatedAt.Format(*layout))
+	// The latest report is replaced last, so that a failed upload is
+	// compared with again.
+	for _, obj := range []struct {
+		name        string
+		data        []byte
+		contentType string
+	}{
+		{path.Join(reportDir, reportJSONFile), b, "application/json"},
+		{path.Join(reportDir, reportMarkdownFile), md.Bytes(), "text/markdown"},
+		{path.Join(dir, latestFile), b, "application/json"},
+	} {
+		if err := minioCl.UploadBytes(ctx, *minioBucket, obj.name, obj.data, minio.PutObjectOptions{
+			ContentType: obj.contentType,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to upload %s: %w", obj.name, err)
+		}
+	}
+	log.Printf("%s: %d findings, report %s", siteURL, len(report.Findings), reportDir)
+	return report.NewFindings(minSeverity), nil
+}
+
+// Returns nil if the site has no report yet.
+func loadReport(ctx context.Context, name string) (*wpaudit.Report, error) {
+	b, err := minioCl.DownloadBytes(ctx, *minioBucket, name)
+	if minioext.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	r := &wpaudit.Report{}
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func host(siteURL string) string {
+	if u, err := neturl.Parse(siteURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return siteURL
+}
+
+func mustValidateConfig() wpaudit.Severity {
+	if *urls == "" {
+		log.Fatal("urls is required")
+	}
+	minSeverity, err := wpaudit.ParseSeverity(*failSeverity)
+	if err != nil {
+		log.Fatalf("invalid fail-severity: %v", err)
+	}
+	if *debugOutput {
+		return minSeverity
+	}
+	if *minioEndpoint == "" {
+		log.Fatal("minio-endpoint is required")
+	}
+	if *minioRegion == "" {
+		log.Fatal("minio-region is required")
+	}
+	if *minioBucket == "" {
+		log.Fatal("minio-bucket is required")
+	}
+	if minioAccessKeyID == "" {
+		log.Fatalf("%s is required", minioAccessKeyIDEnv)
+	}
+	if minioSecretAccessKey == "" {
+		log.Fatalf("%s is required", minioSecretAccessKeyEnv)
+	}
+	return minSeverity
+}
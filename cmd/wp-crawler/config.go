@@ -0,0 +1,374 @@
+package main
+
+import (
+	"fmt"
+	neturl "net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-yaml/yaml"
+
+	"github.com/ozansz/homelab-functions/pkg/minioext"
+	"github.com/ozansz/homelab-functions/pkg/wordpress"
+)
+
+// Configuration of a multi-site crawl, read from the file given with
+// -config. Settings missing from a site default to the values of the
+// corresponding flags. For example:
+//
+//	minio:
+//	  endpoint: minio.example.com:9000
+//	  region: us-east-1
+//	sites:
+//	  - name: blog
+//	    url: https://blog.example.com
+//	    bucket: wordpress
+//	    prefix: blog
+//	    schedule: 24h
+//	    entities: [posts, pages, comments, categories, tags, users]
+//	    incremental: true
+//	    auth:
+//	      user: backup
+//	      app_password_file: /secrets/blog-app-password
+//	    limits:
+//	      concurrency: 2
+//	      rate_limit: 1
+//	      timeout: 30m
+//	    deletions:
+//	      webhook_url: https://hooks.example.com/wordpress
+//	      threshold: 10
+//	    verify:
+//	      enabled: true
+//	      fail: true
+type config struct {
+	Minio struct {
+		Endpoint    string        `yaml:"endpoint"`
+		Region      string        `yaml:"region"`
+		HTTPTimeout time.Duration `yaml:"http_timeout"`
+	} `yaml:"minio"`
+	Sites []siteConfig `yaml:"sites"`
+}
+
+type siteConfig struct {
+	// Used in logs and the summary, defaults to the host of the URL.
+	Name   string `yaml:"name"`
+	URL    string `yaml:"url"`
+	Bucket string `yaml:"bucket"`
+	// Prepended to the names of all objects of the site.
+	Prefix string `yaml:"prefix"`
+	// Go time layout of the snapshot path, e.g. 2006/01/02/15/04.
+	Layout string `yaml:"layout"`
+	// Minimum time between two successful crawls of the site. The site is
+	// skipped until then, so that one frequent CronJob can serve sites with
+	// different schedules.
+	Schedule time.Duration `yaml:"schedule"`
+	// Entity types to crawl, e.g. posts or collections, all if empty.
+	Entities []string `yaml:"entities"`
+	Formats  []string `yaml:"formats"`
+
+	Incremental      bool   `yaml:"incremental"`
+	CheckpointObject string `yaml:"checkpoint_object"`
+	Media            bool   `yaml:"media"`
+	Revisions        bool   `yaml:"revisions"`
+	ScrapeFallback   bool   `yaml:"scrape_fallback"`
+	SelectorsFile    string `yaml:"selectors_file"`
+	// Directory of the HTTP cache, which can be shared by the sites.
+	CacheDir string `yaml:"cache_dir"`
+	// Defaults to -offline only if missing, false overrides the flag.
+	Offline *bool `yaml:"offline"`
+
+	Auth      authConfig      `yaml:"auth"`
+	Limits    limitsConfig    `yaml:"limits"`
+	Deletions deletionsConfig `yaml:"deletions"`
+	Verify    verifyConfig    `yaml:"verify"`
+}
+
+// Notification about the entities deleted since the previous snapshot, which
+// are always recorded in its deletions.json.
+type deletionsConfig struct {
+	// JSON is POSTed to the URL, no notification is sent if empty.
+	WebhookURL string `yaml:"webhook_url"`
+	// Only notify if more entities than this were deleted.
+	Threshold int `yaml:"threshold"`
+}
+
+// Reconciliation of the snapshot with the sitemap and the entity counts of
+// the site, stored in its verification.json. Both default to their flag only
+// if missing, so that a site can opt out with false.
+type verifyConfig struct {
+	Enabled *bool `yaml:"enabled"`
+	// Fail the crawl if the snapshot is incomplete or could not be
+	// verified. It is stored anyway. Implies enabled.
+	Fail *bool `yaml:"fail"`
+}
+
+// Secrets are read from the files if given, otherwise from the environment
+// variables.
+type authConfig struct {
+	User            string `yaml:"user"`
+	AppPasswordFile string `yaml:"app_password_file"`
+	AppPasswordEnv  string `yaml:"app_password_env"`
+	BearerTokenFile string `yaml:"bearer_token_file"`
+	BearerTokenEnv  string `yaml:"bearer_token_env"`
+	CookieFile      string `yaml:"cookie_file"`
+	CookieEnv       string `yaml:"cookie_env"`
+	NonceFile       string `yaml:"nonce_file"`
+	NonceEnv        string `yaml:"nonce_env"`
+}
+
+type limitsConfig struct {
+	HTTPTimeout   time.Duration `yaml:"http_timeout"`
+	PerPage       int           `yaml:"per_page"`
+	Concurrency   int           `yaml:"concurrency"`
+	Retries       *int          `yaml:"retries"`
+	RetryDelay    time.Duration `yaml:"retry_delay"`
+	RetryMaxDelay time.Duration `yaml:"retry_max_delay"`
+	RateLimit     float64       `yaml:"rate_limit"`
+	RateBurst     int           `yaml:"rate_burst"`
+
+	// Crawl budgets. The snapshot is marked as truncated if one runs out.
+	// A timeout of 0 disables the deadline even if -timeout is set.
+	Timeout     *time.Duration `yaml:"timeout"`
+	MaxPages    int            `yaml:"max_pages"`
+	MaxEntities int            `yaml:"max_entities"`
+}
+
+var knownEntities = map[string]bool{
+	wordpress.EntityCategories:  true,
+	wordpress.EntityComments:    true,
+	wordpress.EntityMedia:       true,
+	wordpress.EntityPages:       true,
+	wordpress.EntityPosts:       true,
+	wordpress.EntityTags:        true,
+	wordpress.EntityUsers:       true,
+	wordpress.EntityCollections: true,
+}
+
+func loadConfig(path string) (*config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg config
+	if err := yaml.UnmarshalStrict(b, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+	if cfg.Minio.Endpoint == "" {
+		cfg.Minio.Endpoint = *minioEndpoint
+	}
+	if cfg.Minio.Region == "" {
+		cfg.Minio.Region = *minioRegion
+	}
+	if cfg.Minio.HTTPTimeout == 0 {
+		cfg.Minio.HTTPTimeout = *minioHTTPTimeout
+	}
+	if len(cfg.Sites) == 0 {
+		return nil, fmt.Errorf("no sites configured")
+	}
+	names := make(map[string]bool, len(cfg.Sites))
+	for i := range cfg.Sites {
+		site := &cfg.Sites[i]
+		site.setDefaults()
+		if err := site.validate(); err != nil {
+			return nil, fmt.Errorf("invalid site %d: %w", i, err)
+		}
+		if names[site.Name] {
+			return nil, fmt.Errorf("duplicate site name %q", site.Name)
+		}
+		names[site.Name] = true
+	}
+	return &cfg, nil
+}
+
+// Returns the configuration of the single site given with the flags.
+func siteFromFlags() siteConfig {
+	site := siteConfig{
+		URL:              *url,
+		Bucket:           *minioBucket,
+		Formats:          strings.Split(*formats, ","),
+		Incremental:      *incremental,
+		CheckpointObject: *checkpointObject,
+		Media:            *archiveMediaFiles,
+		Revisions:        *archiveRevs,
+		ScrapeFallback:   *scrapeFallback,
+		SelectorsFile:    *selectorsFile,
+		Auth: authConfig{
+			User:            *wpUser,
+			AppPasswordFile: *wpAppPasswordFile,
+			AppPasswordEnv:  wpAppPasswordEnv,
+			BearerTokenFile: *wpBearerTokenFile,
+			BearerTokenEnv:  wpBearerTokenEnv,
+			CookieFile:      *wpCookieFile,
+			CookieEnv:       wpCookieEnv,
+			NonceFile:       *wpNonceFile,
+			NonceEnv:        wpNonceEnv,
+		},
+	}
+	site.setDefaults()
+	return site
+}
+
+func (s *siteConfig) setDefaults() {
+	if s.Name == "" {
+		s.Name = s.host()
+	}
+	if s.Bucket == "" {
+		s.Bucket = *minioBucket
+	}
+	if s.CacheDir == "" {
+		s.CacheDir = *cacheDir
+	}
+	if s.Offline == nil {
+		s.Offline = offline
+	}
+	if s.Deletions.WebhookURL == "" {
+		s.Deletions.WebhookURL = *deletionsWebhookURL
+	}
+	if s.Deletions.Threshold == 0 {
+		s.Deletions.Threshold = *deletionsThreshold
+	}
+	if s.Verify.Enabled == nil {
+		s.Verify.Enabled = verify
+	}
+	if s.Verify.Fail == nil {
+		s.Verify.Fail = verifyFail
+	}
+	if *s.Verify.Fail {
+		// Not set through the pointer, which may be the flag's.
+		enabled := true
+		s.Verify.Enabled = &enabled
+	}
+	if s.Layout == "" {
+		s.Layout = string(minioext.LayoutYYYYMMDDHHMM)
+	}
+	if len(s.Formats) == 0 {
+		s.Formats = []string{formatJSON}
+	}
+	for i, f := range s.Formats {
+		s.Formats[i] = strings.TrimSpace(f)
+	}
+	if s.Limits.HTTPTimeout == 0 {
+		s.Limits.HTTPTimeout = *httpTimeout
+	}
+	if s.Limits.PerPage == 0 {
+		s.Limits.PerPage = *perPage
+	}
+	if s.Limits.Concurrency == 0 {
+		s.Limits.Concurrency = *concurrency
+	}
+	if s.Limits.Retries == nil {
+		s.Limits.Retries = retries
+	}
+	if s.Limits.RetryDelay == 0 {
+		s.Limits.RetryDelay = *retryDelay
+	}
+	if s.Limits.RetryMaxDelay == 0 {
+		s.Limits.RetryMaxDelay = *retryMax
+	}
+	if s.Limits.RateLimit == 0 {
+		s.Limits.RateLimit = *rateLimit
+	}
+	if s.Limits.RateBurst == 0 {
+		s.Limits.RateBurst = *rateBurst
+	}
+	if s.Limits.Timeout == nil {
+		s.Limits.Timeout = timeout
+	}
+	if s.Limits.MaxPages == 0 {
+		s.Limits.MaxPages = *maxPages
+	}
+	if s.Limits.MaxEntities == 0 {
+		s.Limits.MaxEntities = *maxEntities
+	}
+}
+
+func (s *siteConfig) validate() error {
+	if s.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+	if s.Bucket == "" && !*debugOutput {
+		return fmt.Errorf("bucket is required")
+	}
+	for _, e := range s.Entities {
+		if !knownEntities[e] {
+			return fmt.Errorf("unknown entity type %q", e)
+		}
+	}
+	for _, f := range s.Formats {
+		switch f {
+		case formatJSON, formatWXR, formatNDJSON:
+		default:
+			return fmt.Errorf("unknown output format %q", f)
+		}
+	}
+	if *s.Offline && s.CacheDir == "" {
+		return fmt.Errorf("offline mode requires a cache directory")
+	}
+	if s.hasFormat(formatNDJSON) {
+		// The entities are never held in memory, so nothing else can be
+		// derived from them.
+		switch {
+		case len(s.Formats) > 1:
+			return fmt.Errorf("the ndjson format cannot be combined with other formats")
+		case s.Incremental:
+			return fmt.Errorf("incremental crawling requires the json format")
+		case s.Media:
+			return fmt.Errorf("archiving media is not supported with the ndjson format")
+		case s.Revisions:
+			return fmt.Errorf("archiving revisions is not supported with the ndjson format")
+		case s.ScrapeFallback:
+			return fmt.Errorf("scraping is not supported with the ndjson format")
+		case *s.Verify.Enabled:
+			return fmt.Errorf("verifying the snapshot is not supported with the ndjson format")
+		}
+	}
+	if s.Incremental && !s.hasFormat(formatJSON) {
+		return fmt.Errorf("incremental crawling requires the json format")
+	}
+	if *debugOutput {
+		switch {
+		case s.Incremental:
+			return fmt.Errorf("incremental crawling requires minio")
+		case s.Media:
+			return fmt.Errorf("archiving media requires minio")
+		case s.Revisions:
+			return fmt.Errorf("archiving revisions requires minio")
+		}
+	}
+	return nil
+}
+
+func (s *siteConfig) hasFormat(format string) bool {
+	for _, f := range s.Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *siteConfig) host() string {
+	if u, err := neturl.Parse(s.URL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return s.URL
+}
+
+// Returns the name of an object of the site under its prefix.
+func (s *siteConfig) object(name string) string {
+	if s.Prefix == "" {
+		return name
+	}
+	return strings.TrimSuffix(s.Prefix, "/") + "/" + name
+}
+
+func (s *siteConfig) credentials() (wordpress.Credentials, error) {
+	return wordpress.CredentialSources{
+		Username:    s.Auth.User,
+		AppPassword: wordpress.Secret{Env: s.Auth.AppPasswordEnv, File: s.Auth.AppPasswordFile},
+		BearerToken: wordpress.Secret{Env: s.Auth.BearerTokenEnv, File: s.Auth.BearerTokenFile},
+		Cookie:      wordpress.Secret{Env: s.Auth.CookieEnv, File: s.Auth.CookieFile},
+		Nonce:       wordpress.Secret{Env: s.Auth.NonceEnv, File: s.Auth.NonceFile},
+	}.Read()
+}
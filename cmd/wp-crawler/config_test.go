@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-yaml/yaml"
+)
+
+func TestSetDefaultsKeepsExplicitValues(t *testing.T) {
+	defer func(v, f, o bool, d time.Duration) {
+		*verify, *verifyFail, *offline, *timeout = v, f, o, d
+	}(*verify, *verifyFail, *offline, *timeout)
+	*verify, *verifyFail, *offline, *timeout = true, true, true, time.Hour
+
+	for _, tc := range []struct {
+		name        string
+		yaml        string
+		wantVerify  bool
+		wantFail    bool
+		wantOffline bool
+		wantTimeout time.Duration
+	}{
+		{
+			name:        "flag defaults",
+			yaml:        "url: https://example.com",
+			wantVerify:  true,
+			wantFail:    true,
+			wantOffline: true,
+			wantTimeout: time.Hour,
+		},
+		{
+			name:        "opted out",
+			yaml:        "url: https://example.com\noffline: false\nverify: {enabled: false, fail: false}\nlimits: {timeout: 0}",
+			wantTimeout: 0,
+		},
+		{
+			name:        "fail implies enabled",
+			yaml:        "url: https://example.com\nverify: {enabled: false, fail: true}\nlimits: {timeout: 30m}",
+			wantVerify:  true,
+			wantFail:    true,
+			wantOffline: true,
+			wantTimeout: 30 * time.Minute,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var site siteConfig
+			if err := yaml.UnmarshalStrict([]byte(tc.yaml), &site); err != nil {
+				t.Fatalf("failed to unmarshal site: %v", err)
+			}
+			site.setDefaults()
+			if *site.Verify.Enabled != tc.wantVerify || *site.Verify.Fail != tc.wantFail || *site.Offline != tc.wantOffline || *site.Limits.Timeout != tc.wantTimeout {
+				t.Errorf("setDefaults() = verify %t, fail %t, offline %t, timeout %v, want %t, %t, %t, %v",
+					*site.Verify.Enabled, *site.Verify.Fail, *site.Offline, *site.Limits.Timeout,
+					tc.wantVerify, tc.wantFail, tc.wantOffline, tc.wantTimeout)
+			}
+		})
+	}
+	if !*verify {
+		t.Errorf("setDefaults() changed the -verify flag")
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+
+	"github.com/ozansz/homelab-functions/pkg/minioext"
+	"github.com/ozansz/homelab-functions/pkg/wordpress"
+)
+
+const deletionsFile = "deletions.json"
+
+// Sent to the deletions webhook. Text makes the payload readable by Slack
+// and Mattermost compatible webhooks.
+type deletionsNotification struct {
+	Text      string                `json:"text"`
+	Site      string                `json:"site"`
+	URL       string                `json:"url"`
+	Snapshot  string                `json:"snapshot"`
+	Count     int                   `json:"count"`
+	Deletions []wordpress.Tombstone `json:"deletions"`
+}
+
+// Adds the manifest of the snapshot to data and, if the previous snapshot has
+// a manifest, the entities deleted since then. Returns the deletions, nil if
+// they are unknown.
+func (s *siteCrawler) trackDeletions(ctx context.Context, snapshot string, content *wordpress.SiteContent, data map[string][]byte) ([]wordpress.Tombstone, error) {
+	manifest := content.Manifest(snapshot, s.cfg.Entities...)
+	b, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	data[wordpress.ManifestFile] = b
+
+	if s.previous == "" {
+		return nil, nil
+	}
+	b, err = minioCl.DownloadBytes(ctx, s.cfg.Bucket, path.Join(s.previous, wordpress.ManifestFile))
+	if minioext.IsNotFound(err) {
+		log.Printf("previous snapshot %s has no manifest, not tracking deletions", s.previous)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to download previous manifest: %w", err)
+	}
+	var prev wordpress.Manifest
+	if err := json.Unmarshal(b, &prev); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal previous manifest: %w", err)
+	}
+
+	deletions := manifest.Deletions(&prev)
+	if b, err = json.Marshal(deletions); err != nil {
+		return nil, fmt.Errorf("failed to marshal deletions: %w", err)
+	}
+	data[deletionsFile] = b
+	log.Printf("%d entities were deleted since snapshot %s", len(deletions), prev.Snapshot)
+	return deletions, nil
+}
+
+// Posts the deletions to the webhook if there are more than the threshold.
+func (s *siteCrawler) notifyDeletions(ctx context.Context, snapshot string, deletions []wordpress.Tombstone) error {
+	if s.cfg.Deletions.WebhookURL == "" || len(deletions) <= s.cfg.Deletions.Threshold {
+		return nil
+	}
+	b, err := json.Marshal(deletionsNotification{
+		Text:      fmt.Sprintf("%d entities were deleted from %s since the last crawl, see %s/%s", len(deletions), s.cfg.URL, snapshot, deletionsFile),
+		Site:      s.cfg.Name,
+		URL:       s.cfg.URL,
+		Snapshot:  snapshot,
+		Count:     len(deletions),
+		Deletions: deletions,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.Deletions.WebhookURL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := (&http.Client{Timeout: s.cfg.Limits.HTTPTimeout}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("got unexpected status code: %d", res.StatusCode)
+	}
+	return nil
+}
@@ -1,33 +1,85 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"os"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/minio/minio-go/v7"
 
 	"github.com/ozansz/homelab-functions/pkg/minioext"
 	"github.com/ozansz/homelab-functions/pkg/wordpress"
+	"github.com/ozansz/homelab-functions/pkg/wxr"
 )
 
 const (
 	minioAccessKeyIDEnv     = "MINIO_ACCESS_KEY_ID"
 	minioSecretAccessKeyEnv = "MINIO_SECRET_ACCESS_KEY"
+
+	wpAppPasswordEnv = "WP_APP_PASSWORD"
+	wpBearerTokenEnv = "WP_BEARER_TOKEN"
+	wpCookieEnv      = "WP_COOKIE"
+	wpNonceEnv       = "WP_NONCE"
+
+	formatJSON   = "json"
+	formatWXR    = "wxr"
+	formatNDJSON = "ndjson"
+
+	wxrFile = "wxr.xml"
 )
 
 var (
+	configFile  = flag.String("config", "", "YAML file configuring the sites to crawl, instead of the site flags")
 	url         = flag.String("url", "", "URL of the WordPress site to crawl")
-	timeout     = flag.Duration("timeout", 5*time.Minute, "Timeout for crawler")
+	timeout     = flag.Duration("timeout", 0, "Timeout for crawler, the snapshot is truncated to the entities fetched until then, 0 for no timeout")
 	httpTimeout = flag.Duration("http-timeout", 10*time.Second, "Timeout for HTTP requests")
+	perPage     = flag.Int("per-page", 100, "Number of entities to fetch per page (max 100)")
+	concurrency = flag.Int("concurrency", 4, "Number of pages to fetch in parallel")
+	retries     = flag.Int("retries", 5, "Number of retries of requests failing with a transient error")
+	retryDelay  = flag.Duration("retry-delay", time.Second, "Base delay between retries, doubled on each retry")
+	retryMax    = flag.Duration("retry-max-delay", time.Minute, "Maximum delay between retries")
+	rateLimit   = flag.Float64("rate-limit", 0, "Maximum requests per second sent to the site, 0 for no limit")
+	rateBurst   = flag.Int("rate-burst", 1, "Number of requests that can be sent at once before the rate limit applies")
+	maxPages    = flag.Int("max-pages", 0, "Maximum number of pages fetched per entity type, 0 for no limit")
+	maxEntities = flag.Int("max-entities", 0, "Maximum number of entities fetched per entity type, 0 for no limit")
+	cacheDir    = flag.String("cache-dir", "", "Directory to cache HTTP responses in, revalidated with the site on later runs")
+	offline     = flag.Bool("offline", false, "Serve all requests from the cache directory without contacting the site")
+
+	deletionsWebhookURL = flag.String("deletions-webhook-url", "", "URL to POST the entities deleted since the previous snapshot to")
+	deletionsThreshold  = flag.Int("deletions-threshold", 0, "Only notify the deletions webhook if more entities than this were deleted")
+
+	verify     = flag.Bool("verify", false, "Compare the snapshot with the site's sitemap and entity counts, stored in its verification.json")
+	verifyFail = flag.Bool("verify-fail", false, "Fail the crawl if the verification finds the snapshot incomplete or cannot complete, implies -verify")
 
 	minioEndpoint    = flag.String("minio-endpoint", "", "Minio endpoint")
 	minioRegion      = flag.String("minio-region", "", "Minio region")
 	minioBucket      = flag.String("minio-bucket", "", "Minio bucket")
 	minioHTTPTimeout = flag.Duration("minio-http-timeout", 10*time.Second, "Timeout for Minio HTTP requests")
 
+	incremental      = flag.Bool("incremental", false, "Only fetch entities changed since the last checkpoint and merge them into the previous snapshot")
+	checkpointObject = flag.String("checkpoint-object", "", "Minio object name of the crawl checkpoint (default checkpoints/<host>.json)")
+
+	wpUser            = flag.String("wp-user", "", "WordPress username to authenticate with an application password")
+	wpAppPasswordFile = flag.String("wp-app-password-file", "", "File containing the WordPress application password (default $"+wpAppPasswordEnv+")")
+	wpBearerTokenFile = flag.String("wp-bearer-token-file", "", "File containing a bearer/JWT token (default $"+wpBearerTokenEnv+")")
+	wpCookieFile      = flag.String("wp-cookie-file", "", "File containing a logged in user's cookie (default $"+wpCookieEnv+")")
+	wpNonceFile       = flag.String("wp-nonce-file", "", "File containing the wp_rest nonce for the cookie (default $"+wpNonceEnv+")")
+
+	scrapeFallback = flag.Bool("scrape-fallback", false, "Scrape the posts and pages from their HTML if the REST API is not available")
+	selectorsFile  = flag.String("selectors-file", "", "JSON file with the XPath selectors to scrape with, overriding the defaults")
+
+	archiveMediaFiles = flag.Bool("media", false, "Download media files into the snapshot, copying the files of media not modified since the previous snapshot from it")
+	archiveRevs       = flag.Bool("revisions", false, "Archive the revisions of the crawled posts and pages, requires credentials")
+	formats           = flag.String("formats", formatJSON, "Comma separated output formats of the snapshot: json, wxr, or ndjson alone to stream large sites")
+
 	debugOutput = flag.Bool("debug-output", false, "Debug output")
 
 	minioAccessKeyID     string
@@ -40,58 +92,441 @@ func main() {
 	minioAccessKeyID = os.Getenv(minioAccessKeyIDEnv)
 	minioSecretAccessKey = os.Getenv(minioSecretAccessKeyEnv)
 
-	mustValidateConfig()
+	cfg := mustLoadConfig()
 
 	ctx := context.Background()
 
-	wpCl := wordpress.NewClient(*url, wordpress.WithTimeout(*httpTimeout))
-
-	var err error
 	if !*debugOutput {
-		minioCl, err = minioext.NewClient(*minioEndpoint, *minioRegion, minioext.WithTimeout(*minioHTTPTimeout), minioext.WithCredentials(minioAccessKeyID, minioSecretAccessKey))
+		var err error
+		minioCl, err = minioext.NewClient(cfg.Minio.Endpoint, cfg.Minio.Region, minioext.WithTimeout(cfg.Minio.HTTPTimeout), minioext.WithCredentials(minioAccessKeyID, minioSecretAccessKey))
 		if err != nil {
 			log.Fatalf("failed to create minio client: %v", err)
 		}
 	}
 
-	wpData, err := wpCl.GetAll(ctx)
+	// A failing site must not keep the others from being crawled, so errors
+	// are only reported in the summary.
+	results := make([]siteResult, 0, len(cfg.Sites))
+	for _, site := range cfg.Sites {
+		results = append(results, crawlSite(ctx, site))
+	}
+
+	failed := 0
+	log.Printf("summary:")
+	for _, r := range results {
+		switch {
+		case r.err != nil:
+			failed++
+			log.Printf("  %s: failed after %s: %v", r.name, r.duration.Round(time.Second), r.err)
+		case r.skipped:
+			log.Printf("  %s: skipped, last crawled at %s", r.name, r.lastSuccess.Format(time.RFC3339))
+		default:
+			log.Printf("  %s: ok in %s, snapshot %s", r.name, r.duration.Round(time.Second), r.snapshot)
+		}
+	}
+	if failed > 0 {
+		log.Fatalf("%d of %d sites failed", failed, len(results))
+	}
+
+	log.Println("ok!")
+}
+
+type siteResult struct {
+	name        string
+	snapshot    string
+	duration    time.Duration
+	skipped     bool
+	lastSuccess time.Time
+	err         error
+}
+
+// Stored per site to schedule the crawls and to report their state.
+type siteStatus struct {
+	Name        string    `json:"name"`
+	URL         string    `json:"url"`
+	LastAttempt time.Time `json:"last_attempt"`
+	LastSuccess time.Time `json:"last_success,omitempty"`
+	Snapshot    string    `json:"snapshot,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	// Classifies the error, see errorKind.
+	ErrorKind string `json:"error_kind,omitempty"`
+}
+
+func crawlSite(ctx context.Context, site siteConfig) siteResult {
+	res := siteResult{name: site.Name}
+	if *debugOutput {
+		log.Printf("crawling %s (%s)", site.Name, site.URL)
+		start := time.Now()
+		_, res.err = (&siteCrawler{cfg: site}).crawl(ctx)
+		res.duration = time.Since(start)
+		return res
+	}
+
+	st, err := loadStatus(ctx, site)
 	if err != nil {
-		log.Fatalf("failed to get all data from WordPress: %v", err)
+		res.err = fmt.Errorf("failed to load status: %w", err)
+		return res
+	}
+	if site.Schedule > 0 && time.Since(st.LastSuccess) < site.Schedule {
+		res.skipped, res.lastSuccess = true, st.LastSuccess
+		return res
+	}
+
+	log.Printf("crawling %s (%s)", site.Name, site.URL)
+	start := time.Now()
+	res.snapshot, res.err = (&siteCrawler{cfg: site, previous: st.Snapshot}).crawl(ctx)
+	res.duration = time.Since(start)
+
+	st.Name, st.URL, st.LastAttempt, st.Error, st.ErrorKind = site.Name, site.URL, start.UTC(), "", ""
+	if res.err != nil {
+		st.Error, st.ErrorKind = res.err.Error(), errorKind(res.err)
+	} else {
+		st.LastSuccess, st.Snapshot = st.LastAttempt, res.snapshot
+	}
+	if err := saveStatus(ctx, site, st); err != nil && res.err == nil {
+		res.err = fmt.Errorf("failed to save status: %w", err)
 	}
+	return res
+}
 
-	data, err := wpData.Marshal()
+// Tells a site that is down from one that restricts its REST API, e.g. with a
+// security plugin.
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, wordpress.ErrServerError):
+		return "server_error"
+	case errors.Is(err, wordpress.ErrUnauthorized):
+		return "unauthorized"
+	case errors.Is(err, wordpress.ErrForbidden):
+		return "forbidden"
+	case errors.Is(err, wordpress.ErrNoRoute):
+		return "no_route"
+	}
+	return ""
+}
+
+func statusObjectName(site siteConfig) string {
+	return site.object(fmt.Sprintf("status/%s.json", site.host()))
+}
+
+func loadStatus(ctx context.Context, site siteConfig) (*siteStatus, error) {
+	st := &siteStatus{}
+	b, err := minioCl.DownloadBytes(ctx, site.Bucket, statusObjectName(site))
+	if minioext.IsNotFound(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, st); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+func saveStatus(ctx context.Context, site siteConfig, st *siteStatus) error {
+	b, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return minioCl.UploadBytes(ctx, site.Bucket, statusObjectName(site), b, minio.PutObjectOptions{
+		ContentType: "application/json",
+	})
+}
+
+type siteCrawler struct {
+	cfg siteConfig
+	// Path prefix of the last successful snapshot, if any.
+	previous string
+	wpCl     *wordpress.Client
+}
+
+// Crawls the site and returns the path prefix of the stored snapshot.
+func (s *siteCrawler) crawl(ctx context.Context) (string, error) {
+	wpOpts := []wordpress.NewClientOpt{
+		wordpress.WithTimeout(s.cfg.Limits.HTTPTimeout),
+		wordpress.WithPerPage(s.cfg.Limits.PerPage),
+		wordpress.WithConcurrency(s.cfg.Limits.Concurrency),
+		wordpress.WithRetries(*s.cfg.Limits.Retries, s.cfg.Limits.RetryDelay, s.cfg.Limits.RetryMaxDelay),
+		wordpress.WithRateLimit(s.cfg.Limits.RateLimit, s.cfg.Limits.RateBurst),
+		wordpress.WithMaxPages(s.cfg.Limits.MaxPages),
+		wordpress.WithMaxEntities(s.cfg.Limits.MaxEntities),
+	}
+	if *s.cfg.Limits.Timeout > 0 {
+		wpOpts = append(wpOpts, wordpress.WithDeadline(time.Now().Add(*s.cfg.Limits.Timeout)))
+	}
+	if len(s.cfg.Entities) > 0 {
+		wpOpts = append(wpOpts, wordpress.WithEntities(s.cfg.Entities...))
+	}
+	if s.cfg.CacheDir != "" {
+		wpOpts = append(wpOpts, wordpress.WithCache(s.cfg.CacheDir))
+	}
+	if *s.cfg.Offline {
+		wpOpts = append(wpOpts, wordpress.WithOffline())
+	}
+	creds, err := s.cfg.credentials()
+	if err != nil {
+		return "", fmt.Errorf("failed to read WordPress credentials: %w", err)
+	}
+	authOpts, err := creds.Options()
 	if err != nil {
-		log.Fatalf("failed to marshal data: %v", err)
+		return "", fmt.Errorf("failed to read WordPress credentials: %w", err)
+	}
+	if s.cfg.Revisions && len(authOpts) == 0 {
+		return "", fmt.Errorf("archiving revisions requires WordPress credentials")
+	}
+	s.wpCl = wordpress.NewClient(s.cfg.URL, append(wpOpts, authOpts...)...)
+	if s.cfg.hasFormat(formatNDJSON) {
+		return s.stream(ctx)
+	}
+
+	// Changed holds the entities fetched in this run, which is everything
+	// unless crawling incrementally.
+	var wpData, changed *wordpress.SiteContent
+	scraped := false
+	if s.cfg.ScrapeFallback && !s.wpCl.RESTAvailable(ctx) {
+		log.Printf("scraping the site instead")
+		wpData, err = s.scrape(ctx)
+		scraped = true
+	} else if s.cfg.Incremental {
+		wpData, changed, err = s.getIncremental(ctx)
+	} else {
+		wpData, err = s.wpCl.GetAll(ctx)
+		changed = wpData
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get all data from WordPress: %w", err)
+	}
+	for _, t := range wpData.Truncated {
+		log.Printf("snapshot is truncated, %s stopped at %d entities: %s", t.Endpoint, t.Fetched, t.Reason)
+	}
+	// Scraped sites have no REST API to count the entities with.
+	var verifyErr error
+	if *s.cfg.Verify.Enabled && scraped {
+		verifyErr = errors.New("scraped snapshots cannot be verified")
+		log.Printf("skipping verification: %v", verifyErr)
+	} else if *s.cfg.Verify.Enabled {
+		if v, err := s.wpCl.Verify(ctx, wpData); err != nil {
+			verifyErr = err
+			log.Printf("failed to verify the snapshot: %v", err)
+		} else {
+			wpData.Verification = v
+			for _, p := range v.Problems() {
+				log.Printf("snapshot is incomplete, %s", p)
+			}
+		}
+	}
+
+	data := make(map[string][]byte)
+	if s.cfg.hasFormat(formatJSON) {
+		if data, err = wpData.Marshal(); err != nil {
+			return "", fmt.Errorf("failed to marshal data: %w", err)
+		}
+	}
+	var wxrData []byte
+	if s.cfg.hasFormat(formatWXR) {
+		if wxrData, err = s.exportWXR(ctx, wpData); err != nil {
+			return "", fmt.Errorf("failed to export WXR: %w", err)
+		}
 	}
 
 	if *debugOutput {
 		log.Printf("data: %s", data)
-		return
+		if wxrData != nil {
+			log.Printf("wxr: %s", wxrData)
+		}
+		return "", nil
 	}
 
-	if err := minioCl.BatchUploadBytesWithDateTimePath(ctx, *minioBucket, data, minioext.LayoutYYYYMMDDHHMM, minio.PutObjectOptions{
+	snapshot := s.cfg.object(time.Now().UTC().Format(s.cfg.Layout))
+	if s.cfg.Media {
+		if data[wordpress.MediaObjectsFile], err = s.archiveMedia(ctx, snapshot, wpData.Media); err != nil {
+			return "", fmt.Errorf("failed to archive media: %w", err)
+		}
+	}
+	// Scraped entities may have synthetic IDs, which cannot be compared
+	// across snapshots.
+	var deletions []wordpress.Tombstone
+	if s.cfg.hasFormat(formatJSON) && !scraped {
+		if deletions, err = s.trackDeletions(ctx, snapshot, wpData, data); err != nil {
+			return "", fmt.Errorf("failed to track deletions: %w", err)
+		}
+	}
+	if err := minioCl.BatchUploadBytesWithPrefix(ctx, s.cfg.Bucket, snapshot, data, minio.PutObjectOptions{
 		ContentType: "application/json",
 	}); err != nil {
-		log.Fatalf("failed to upload data to minio: %v", err)
+		return "", fmt.Errorf("failed to upload data to minio: %w", err)
+	}
+	if wxrData != nil {
+		if err := minioCl.UploadBytes(ctx, s.cfg.Bucket, path.Join(snapshot, wxrFile), wxrData, minio.PutObjectOptions{
+			ContentType: "application/xml",
+		}); err != nil {
+			return "", fmt.Errorf("failed to upload WXR export to minio: %w", err)
+		}
 	}
 
-	log.Println("ok!")
+	if err := s.notifyDeletions(ctx, snapshot, deletions); err != nil {
+		log.Printf("failed to notify about deletions: %v", err)
+	}
+
+	if s.cfg.Revisions {
+		if scraped {
+			log.Printf("skipping revisions, they are not available without the REST API")
+		} else if err := s.archiveRevisions(ctx, changed); err != nil {
+			// The snapshot is already stored. The posts and pages whose
+			// revisions failed are recorded as pending and retried on the
+			// next run, even an incremental one.
+			log.Printf("failed to archive revisions: %v", err)
+		}
+	}
+
+	// The next incremental crawl would skip the entities left out, or merge
+	// the REST entities into scraped ones with synthetic IDs.
+	if s.cfg.Incremental && len(wpData.Truncated) > 0 {
+		log.Printf("not saving the checkpoint of a truncated snapshot")
+	} else if s.cfg.Incremental && scraped {
+		log.Printf("not saving the checkpoint of a scraped snapshot")
+	} else if s.cfg.Incremental {
+		if err := s.saveCheckpoint(ctx, wpData.Checkpoint(snapshot)); err != nil {
+			return "", fmt.Errorf("failed to save checkpoint: %w", err)
+		}
+	}
+	if *s.cfg.Verify.Fail && verifyErr != nil {
+		return snapshot, fmt.Errorf("failed to verify snapshot %s: %w", snapshot, verifyErr)
+	}
+	if v := wpData.Verification; v != nil && *s.cfg.Verify.Fail {
+		if problems := v.Problems(); len(problems) > 0 {
+			return snapshot, fmt.Errorf("snapshot %s is incomplete: %s", snapshot, strings.Join(problems, "; "))
+		}
+	}
+	return snapshot, nil
+}
+
+// Exports the content as WXR, with the site details taken from the REST API
+// index if it is available.
+func (s *siteCrawler) exportWXR(ctx context.Context, content *wordpress.SiteContent) ([]byte, error) {
+	opts := wxr.Options{SiteURL: s.cfg.URL, SiteTitle: s.cfg.URL}
+	if idx, err := s.wpCl.GetIndex(ctx); err == nil {
+		opts.SiteTitle = idx.Name
+		opts.SiteDescription = idx.Description
+		opts.SiteURL = idx.URL
+	} else {
+		log.Printf("failed to get site details, exporting without them: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := wxr.Write(&buf, content, opts); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
-func mustValidateConfig() {
-	if *url == "" {
-		log.Fatal("url is required")
+func (s *siteCrawler) scrape(ctx context.Context) (*wordpress.SiteContent, error) {
+	selectors := wordpress.DefaultSelectors
+	if s.cfg.SelectorsFile != "" {
+		b, err := os.ReadFile(s.cfg.SelectorsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read selectors: %w", err)
+		}
+		if err := json.Unmarshal(b, &selectors); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal selectors: %w", err)
+		}
+	}
+	return s.wpCl.Scrape(ctx, selectors)
+}
+
+// Returns the merged content and the changes fetched from the site.
+func (s *siteCrawler) getIncremental(ctx context.Context) (*wordpress.SiteContent, *wordpress.SiteContent, error) {
+	cp, err := s.loadCheckpoint(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+	if cp == nil {
+		log.Printf("no checkpoint found, doing a full crawl")
+		content, err := s.wpCl.GetAll(ctx)
+		return content, content, err
+	}
+
+	log.Printf("found checkpoint of snapshot %s", cp.Snapshot)
+	files, err := minioCl.BatchDownloadBytesWithPrefix(ctx, s.cfg.Bucket, cp.Snapshot)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to download previous snapshot: %w", err)
+	}
+	content, err := wordpress.UnmarshalSiteContent(files)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal previous snapshot: %w", err)
+	}
+	changes, err := s.wpCl.GetChanged(ctx, cp)
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Printf("got %d changed posts, %d changed pages and %d new comments", len(changes.Posts), len(changes.Pages), len(changes.Comments))
+	content.Merge(changes)
+	if err := s.wpCl.PruneDeleted(ctx, content); err != nil {
+		return nil, nil, err
+	}
+	content.Truncated = s.wpCl.Truncations()
+	return content, changes, nil
+}
+
+// Returns nil if there is no checkpoint yet.
+func (s *siteCrawler) loadCheckpoint(ctx context.Context) (*wordpress.Checkpoint, error) {
+	b, err := minioCl.DownloadBytes(ctx, s.cfg.Bucket, s.checkpointObjectName())
+	if minioext.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cp wordpress.Checkpoint
+	if err := json.Unmarshal(b, &cp); err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+func (s *siteCrawler) saveCheckpoint(ctx context.Context, cp *wordpress.Checkpoint) error {
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	return minioCl.UploadBytes(ctx, s.cfg.Bucket, s.checkpointObjectName(), b, minio.PutObjectOptions{
+		ContentType: "application/json",
+	})
+}
+
+func (s *siteCrawler) checkpointObjectName() string {
+	if s.cfg.CheckpointObject != "" {
+		return s.cfg.CheckpointObject
+	}
+	return s.cfg.object(fmt.Sprintf("checkpoints/%s.json", s.cfg.host()))
+}
+
+func mustLoadConfig() *config {
+	cfg := &config{}
+	if *configFile != "" {
+		var err error
+		if cfg, err = loadConfig(*configFile); err != nil {
+			log.Fatalf("failed to load config: %v", err)
+		}
+	} else {
+		if *url == "" {
+			log.Fatal("url is required")
+		}
+		cfg.Minio.Endpoint = *minioEndpoint
+		cfg.Minio.Region = *minioRegion
+		cfg.Minio.HTTPTimeout = *minioHTTPTimeout
+		cfg.Sites = []siteConfig{siteFromFlags()}
+		if err := cfg.Sites[0].validate(); err != nil {
+			log.Fatal(err)
+		}
 	}
 	if !*debugOutput {
-		if *minioEndpoint == "" {
+		if cfg.Minio.Endpoint == "" {
 			log.Fatal("minio-endpoint is required")
 		}
-		if *minioRegion == "" {
+		if cfg.Minio.Region == "" {
 			log.Fatal("minio-region is required")
 		}
-		if *minioBucket == "" {
-			log.Fatal("minio-bucket is required")
-		}
 		if minioAccessKeyID == "" {
 			log.Fatalf("%s is required", minioAccessKeyIDEnv)
 		}
@@ -99,4 +534,5 @@ func mustValidateConfig() {
 			log.Fatalf("%s is required", minioSecretAccessKeyEnv)
 		}
 	}
+	return cfg
 }
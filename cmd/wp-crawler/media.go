@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	neturl "net/url"
+	"path"
+	"sync"
+
+	"github.com/minio/minio-go/v7"
+
+	"github.com/ozansz/homelab-functions/pkg/minioext"
+	"github.com/ozansz/homelab-functions/pkg/wordpress"
+)
+
+// A file of a media item.
+type mediaFile struct {
+	media int
+	url   string
+}
+
+// A file to archive. It is copied from the previous snapshot if copyFrom is
+// set, and downloaded from the site otherwise.
+type mediaJob struct {
+	file     wordpress.ArchivedFile
+	copyFrom string
+}
+
+// Archives the original file and all registered sizes of each media item
+// into <snapshot>/media/<id>/ and returns the media ID to object mapping as
+// an additional snapshot file. The files of media not modified since the
+// previous snapshot are copied from it within the bucket instead of being
+// downloaded again, so that every snapshot stays self-contained. Files that
+// fail to archive are recorded in the mapping with the error instead of
+// failing the whole crawl.
+func (s *siteCrawler) archiveMedia(ctx context.Context, snapshot string, media []wordpress.Media) ([]byte, error) {
+	prev, err := s.loadPreviousMediaObjects(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load previous media objects: %w", err)
+	}
+
+	objects := make(wordpress.MediaObjects, len(media))
+	jobs := make(map[mediaFile]mediaJob)
+	copies := 0
+	for _, m := range media {
+		archived := make(wordpress.ArchivedMedia)
+		for size, fileURL := range m.FileURLs() {
+			// Filled in once archived, sizes sharing a URL are archived
+			// once.
+			archived[size] = wordpress.ArchivedFile{SourceURL: fileURL}
+			mf := mediaFile{m.ID, fileURL}
+			if _, ok := jobs[mf]; ok {
+				continue
+			}
+			job := mediaJob{file: wordpress.ArchivedFile{
+				Key:         mediaKey(snapshot, m.ID, fileURL),
+				SourceURL:   fileURL,
+				ModifiedGMT: m.ModifiedGMT,
+			}}
+			if f, ok := reusableFile(prev[m.ID], m, fileURL); ok {
+				job.copyFrom = f.Key
+				job.file.SHA256, job.file.Size = f.SHA256, f.Size
+				copies++
+			}
+			jobs[mf] = job
+		}
+		objects[m.ID] = archived
+	}
+	log.Printf("archiving %d media files, copying %d of them from the previous snapshot", len(jobs), copies)
+
+	files := s.archiveMediaFiles(ctx, jobs)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	for id, archived := range objects {
+		for size, f := range archived {
+			archived[size] = files[mediaFile{id, f.SourceURL}]
+		}
+	}
+	return json.Marshal(objects)
+}
+
+// Returns the object name of a media file in the snapshot, named after the
+// file in the URL's path, without its query.
+func mediaKey(snapshot string, id int, fileURL string) string {
+	name := path.Base(fileURL)
+	if u, err := neturl.Parse(fileURL); err == nil && u.Path != "" {
+		name = path.Base(u.Path)
+	}
+	return fmt.Sprintf("%s/media/%d/%s", snapshot, id, name)
+}
+
+// Returns the file archived by the previous snapshot if the media has not
+// been modified since.
+func reusableFile(prev wordpress.ArchivedMedia, m wordpress.Media, fileURL string) (wordpress.ArchivedFile, bool) {
+	if m.ModifiedGMT == "" {
+		return wordpress.ArchivedFile{}, false
+	}
+	for _, f := range prev {
+		if f.SourceURL == fileURL && f.Key != "" && f.Error == "" && f.ModifiedGMT == m.ModifiedGMT {
+			return f, true
+		}
+	}
+	return wordpress.ArchivedFile{}, false
+}
+
+// Archives the files, the configured concurrency of them in parallel. Files
+// that fail are returned with the error.
+func (s *siteCrawler) archiveMediaFiles(ctx context.Context, jobs map[mediaFile]mediaJob) map[mediaFile]wordpress.ArchivedFile {
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	files := make(map[mediaFile]wordpress.ArchivedFile, len(jobs))
+	queue := make(chan mediaFile)
+	workers := s.cfg.Limits.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for mf := range queue {
+				f, err := s.archiveMediaFile(ctx, jobs[mf])
+				if err != nil {
+					if ctx.Err() != nil {
+						continue
+					}
+					log.Printf("failed to archive media %d (%s): %v", mf.media, mf.url, err)
+					f = wordpress.ArchivedFile{SourceURL: f.SourceURL, Error: err.Error()}
+				}
+				mu.Lock()
+				files[mf] = f
+				mu.Unlock()
+			}
+		}()
+	}
+	for mf := range jobs {
+		if ctx.Err() != nil {
+			break
+		}
+		queue <- mf
+	}
+	close(queue)
+	wg.Wait()
+	return files
+}
+
+// Copies the file from the previous snapshot, or downloads it if it has to
+// or the copy fails, e.g. because the previous snapshot was deleted.
+func (s *siteCrawler) archiveMediaFile(ctx context.Context, job mediaJob) (wordpress.ArchivedFile, error) {
+	f := job.file
+	if job.copyFrom != "" {
+		err := minioCl.CopyObject(ctx, s.cfg.Bucket, job.copyFrom, f.Key)
+		if err == nil {
+			return f, nil
+		}
+		if ctx.Err() != nil {
+			return f, ctx.Err()
+		}
+		log.Printf("failed to copy %s from the previous snapshot, downloading it again: %v", job.copyFrom, err)
+		f.SHA256, f.Size = "", 0
+	}
+	err := s.archiveFile(ctx, &f)
+	return f, err
+}
+
+// Returns the media objects of the previous snapshot, none if there is no
+// previous snapshot or it was taken without media.
+func (s *siteCrawler) loadPreviousMediaObjects(ctx context.Context) (wordpress.MediaObjects, error) {
+	if s.previous == "" {
+		return nil, nil
+	}
+	b, err := minioCl.DownloadBytes(ctx, s.cfg.Bucket, path.Join(s.previous, wordpress.MediaObjectsFile))
+	if minioext.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return wordpress.UnmarshalMediaObjects(map[string][]byte{wordpress.MediaObjectsFile: b})
+}
+
+// Downloads the file from its source URL, uploads it to its key and sets
+// its hash and size.
+func (s *siteCrawler) archiveFile(ctx context.Context, f *wordpress.ArchivedFile) error {
+	res, err := s.wpCl.Download(ctx, f.SourceURL)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	h := sha256.New()
+	cr := &countingReader{r: io.TeeReader(res.Body, h)}
+	if err := minioCl.UploadReader(ctx, s.cfg.Bucket, f.Key, cr, res.ContentLength, minio.PutObjectOptions{
+		ContentType: res.Header.Get("Content-Type"),
+	}); err != nil {
+		return fmt.Errorf("failed to upload to minio: %w", err)
+	}
+	f.SHA256 = hex.EncodeToString(h.Sum(nil))
+	f.Size = cr.n
+	return nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/ozansz/homelab-functions/pkg/wordpress"
+)
+
+func TestReusableFile(t *testing.T) {
+	const fileURL = "https://example.com/wp-content/uploads/a.jpg"
+	archived := wordpress.ArchivedFile{
+		Key:         "snapshots/2024/media/1/a.jpg",
+		SourceURL:   fileURL,
+		SHA256:      "abc",
+		Size:        3,
+		ModifiedGMT: "2024-01-01T00:00:00",
+	}
+	for _, tc := range []struct {
+		name    string
+		prev    wordpress.ArchivedMedia
+		media   wordpress.Media
+		fileURL string
+		want    bool
+	}{
+		{
+			name:    "unmodified",
+			prev:    wordpress.ArchivedMedia{"original": archived},
+			media:   wordpress.Media{ID: 1, ModifiedGMT: "2024-01-01T00:00:00"},
+			fileURL: fileURL,
+			want:    true,
+		},
+		{
+			name:    "modified",
+			prev:    wordpress.ArchivedMedia{"original": archived},
+			media:   wordpress.Media{ID: 1, ModifiedGMT: "2024-02-01T00:00:00"},
+			fileURL: fileURL,
+		},
+		{
+			name:    "other URL",
+			prev:    wordpress.ArchivedMedia{"original": archived},
+			media:   wordpress.Media{ID: 1, ModifiedGMT: "2024-01-01T00:00:00"},
+			fileURL: "https://example.com/wp-content/uploads/a-scaled.jpg",
+		},
+		{
+			name:    "no modified date",
+			prev:    wordpress.ArchivedMedia{"original": {Key: archived.Key, SourceURL: fileURL}},
+			media:   wordpress.Media{ID: 1},
+			fileURL: fileURL,
+		},
+		{
+			name: "failed",
+			prev: wordpress.ArchivedMedia{"original": {
+				SourceURL:   fileURL,
+				Error:       "404 Not Found",
+				ModifiedGMT: "2024-01-01T00:00:00",
+			}},
+			media:   wordpress.Media{ID: 1, ModifiedGMT: "2024-01-01T00:00:00"},
+			fileURL: fileURL,
+		},
+		{
+			name:    "not archived",
+			media:   wordpress.Media{ID: 1, ModifiedGMT: "2024-01-01T00:00:00"},
+			fileURL: fileURL,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := reusableFile(tc.prev, tc.media, tc.fileURL)
+			if ok != tc.want {
+				t.Fatalf("reusableFile() ok = %v, want %v", ok, tc.want)
+			}
+			if ok {
+				if diff := cmp.Diff(archived, got); diff != "" {
+					t.Errorf("reusableFile() mismatch (-want +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestMediaKey(t *testing.T) {
+	for _, tc := range []struct {
+		fileURL string
+		want    string
+	}{
+		{"https://example.com/wp-content/uploads/2024/01/a.jpg", "snap/media/1/a.jpg"},
+		{"https://example.com/wp-content/uploads/2024/01/a.jpg?ver=2", "snap/media/1/a.jpg"},
+		{"https://example.com/wp-content/uploads/a%20b.jpg#top", "snap/media/1/a b.jpg"},
+	} {
+		if got := mediaKey("snap", 1, tc.fileURL); got != tc.want {
+			t.Errorf("mediaKey(%q) = %q, want %q", tc.fileURL, got, tc.want)
+		}
+	}
+}
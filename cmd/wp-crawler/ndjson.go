@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+
+	"github.com/ozansz/homelab-functions/pkg/wordpress"
+)
+
+// Streams the entities of the site as NDJSON into the snapshot while the
+// pages are being fetched, so that sites of any size are crawled in constant
+// memory. Returns the path prefix of the snapshot.
+func (s *siteCrawler) stream(ctx context.Context) (string, error) {
+	snapshot := s.cfg.object(time.Now().UTC().Format(s.cfg.Layout))
+	open := func(name string) (io.WriteCloser, error) {
+		return minioCl.NewObjectWriter(ctx, s.cfg.Bucket, path.Join(snapshot, name), minio.PutObjectOptions{
+			ContentType: "application/x-ndjson",
+		})
+	}
+	if *debugOutput {
+		open = func(string) (io.WriteCloser, error) {
+			return nopCloser{os.Stdout}, nil
+		}
+	}
+
+	collections, err := s.wpCl.StreamAll(ctx, open)
+	if err != nil {
+		return "", fmt.Errorf("failed to stream data from WordPress: %w", err)
+	}
+	if *debugOutput {
+		return "", nil
+	}
+	data := make(map[string][]byte)
+	if collections != nil {
+		if data[wordpress.EntityCollections+".json"], err = json.Marshal(collections); err != nil {
+			return "", fmt.Errorf("failed to marshal collections: %w", err)
+		}
+	}
+	if site, err := s.wpCl.GetSiteInfo(ctx); err != nil {
+		log.Printf("failed to fingerprint the site: %v", err)
+	} else if data[wordpress.SiteFile], err = json.Marshal(site); err != nil {
+		return "", fmt.Errorf("failed to marshal site info: %w", err)
+	}
+	if truncated := s.wpCl.Truncations(); len(truncated) > 0 {
+		for _, t := range truncated {
+			log.Printf("snapshot is truncated, %s stopped at %d entities: %s", t.Endpoint, t.Fetched, t.Reason)
+		}
+		if data[wordpress.TruncatedFile], err = json.Marshal(truncated); err != nil {
+			return "", fmt.Errorf("failed to marshal truncations: %w", err)
+		}
+	}
+	if err := minioCl.BatchUploadBytesWithPrefix(ctx, s.cfg.Bucket, snapshot, data, minio.PutObjectOptions{
+		ContentType: "application/json",
+	}); err != nil {
+		return "", fmt.Errorf("failed to upload data to minio: %w", err)
+	}
+	return snapshot, nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/minio/minio-go/v7"
+
+	"github.com/ozansz/homelab-functions/pkg/minioext"
+	"github.com/ozansz/homelab-functions/pkg/wordpress"
+)
+
+// Lists the posts and pages whose revisions could not be fetched, keyed by
+// entity type, so that the next run retries them even if they did not
+// change.
+const pendingRevisionsFile = "pending.json"
+
+// Archives the revisions of the posts and pages in the content under
+// <prefix>/revisions/<host>/<entity>/<id>/<revision-id>.json. Revisions never
+// change, so they are kept outside the snapshots and each one is uploaded
+// once. The revisions that could be fetched are archived even if others
+// failed, the failed entities are retried on the next run.
+func (s *siteCrawler) archiveRevisions(ctx context.Context, content *wordpress.SiteContent) error {
+	pending, err := s.loadPendingRevisions(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load pending revisions: %w", err)
+	}
+	ids := make(map[string]map[int]bool)
+	add := func(entity string, id int) {
+		if ids[entity] == nil {
+			ids[entity] = make(map[int]bool)
+		}
+		ids[entity][id] = true
+	}
+	for entity, pendingIDs := range pending {
+		for _, id := range pendingIDs {
+			add(entity, id)
+		}
+	}
+	for _, p := range content.Posts {
+		add(wordpress.EntityPosts, p.ID)
+	}
+	for _, p := range content.Pages {
+		add(wordpress.EntityPages, p.ID)
+	}
+
+	failed := make(map[string][]int)
+	failures := 0
+	for _, entity := range []string{wordpress.EntityPosts, wordpress.EntityPages} {
+		if len(ids[entity]) == 0 {
+			continue
+		}
+		prefix := s.cfg.object(fmt.Sprintf("revisions/%s/%s", s.cfg.host(), entity))
+		names, err := minioCl.ListObjectNames(ctx, s.cfg.Bucket, prefix+"/")
+		if err != nil {
+			return fmt.Errorf("failed to list archived revisions: %w", err)
+		}
+		archived := make(map[string]bool, len(names))
+		for _, name := range names {
+			archived[name] = true
+		}
+
+		entityIDs := make([]int, 0, len(ids[entity]))
+		for id := range ids[entity] {
+			entityIDs = append(entityIDs, id)
+		}
+		sort.Ints(entityIDs)
+		revisions, errs, err := s.wpCl.GetRevisionsOf(ctx, entity, entityIDs)
+		if err != nil {
+			return err
+		}
+		for id, err := range errs {
+			log.Printf("%v", err)
+			failed[entity] = append(failed[entity], id)
+		}
+		sort.Ints(failed[entity])
+		failures += len(errs)
+
+		objects := make(map[string][]byte)
+		for id, revs := range revisions {
+			for _, r := range revs {
+				name := fmt.Sprintf("%d/%d.json", id, r.ID)
+				if archived[prefix+"/"+name] {
+					continue
+				}
+				b, err := json.Marshal(r)
+				if err != nil {
+					return fmt.Errorf("failed to marshal revision %d: %w", r.ID, err)
+				}
+				objects[name] = b
+			}
+		}
+		if err := minioCl.BatchUploadBytesWithPrefix(ctx, s.cfg.Bucket, prefix, objects, minio.PutObjectOptions{
+			ContentType: "application/json",
+		}); err != nil {
+			return fmt.Errorf("failed to upload revisions: %w", err)
+		}
+		log.Printf("archived %d new revisions of %s", len(objects), entity)
+	}
+
+	if err := s.savePendingRevisions(ctx, failed); err != nil {
+		return fmt.Errorf("failed to save pending revisions: %w", err)
+	}
+	if failures > 0 {
+		return fmt.Errorf("failed to get the revisions of %d posts and pages, retrying them on the next run", failures)
+	}
+	return nil
+}
+
+// Returns nil if no revisions are pending.
+func (s *siteCrawler) loadPendingRevisions(ctx context.Context) (map[string][]int, error) {
+	b, err := minioCl.DownloadBytes(ctx, s.cfg.Bucket, s.pendingRevisionsObjectName())
+	if minioext.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var pending map[string][]int
+	if err := json.Unmarshal(b, &pending); err != nil {
+		return nil, err
+	}
+	return pending, nil
+}
+
+func (s *siteCrawler) savePendingRevisions(ctx context.Context, pending map[string][]int) error {
+	b, err := json.Marshal(pending)
+	if err != nil {
+		return err
+	}
+	return minioCl.UploadBytes(ctx, s.cfg.Bucket, s.pendingRevisionsObjectName(), b, minio.PutObjectOptions{
+		ContentType: "application/json",
+	})
+}
+
+func (s *siteCrawler) pendingRevisionsObjectName() string {
+	return s.cfg.object(fmt.Sprintf("revisions/%s/%s", s.cfg.host(), pendingRevisionsFile))
+}
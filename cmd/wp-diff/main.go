@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ozansz/homelab-functions/pkg/minioext"
+	"github.com/ozansz/homelab-functions/pkg/snapshotdiff"
+	"github.com/ozansz/homelab-functions/pkg/wordpress"
+)
+
+const (
+	minioAccessKeyIDEnv     = "MINIO_ACCESS_KEY_ID"
+	minioSecretAccessKeyEnv = "MINIO_SECRET_ACCESS_KEY"
+
+	formatText = "text"
+	formatJSON = "json"
+)
+
+var (
+	from   = flag.String("from", "", "Path prefix of the older snapshot, e.g. 2023/04/01/03/00")
+	to     = flag.String("to", "", "Path prefix of the newer snapshot, e.g. 2023/04/02/03/00")
+	format = flag.String("format", formatText, "Report format: text or json")
+	output = flag.String("output", "", "File to write the report to (default stdout)")
+
+	minioEndpoint    = flag.String("minio-endpoint", "", "Minio endpoint")
+	minioRegion      = flag.String("minio-region", "", "Minio region")
+	minioBucket      = flag.String("minio-bucket", "", "Minio bucket")
+	minioHTTPTimeout = flag.Duration("minio-http-timeout", 10*time.Second, "Timeout for Minio HTTP requests")
+
+	minioAccessKeyID     string
+	minioSecretAccessKey string
+	minioCl              *minioext.Client
+)
+
+func main() {
+	flag.Parse()
+	minioAccessKeyID = os.Getenv(minioAccessKeyIDEnv)
+	minioSecretAccessKey = os.Getenv(minioSecretAccessKeyEnv)
+
+	mustValidateConfig()
+
+	ctx := context.Background()
+
+	var err error
+	minioCl, err = minioext.NewClient(*minioEndpoint, *minioRegion, minioext.WithTimeout(*minioHTTPTimeout), minioext.WithCredentials(minioAccessKeyID, minioSecretAccessKey))
+	if err != nil {
+		log.Fatalf("failed to create minio client: %v", err)
+	}
+
+	fromContent, err := loadSnapshot(ctx, *from)
+	if err != nil {
+		log.Fatalf("failed to load snapshot %s: %v", *from, err)
+	}
+	toContent, err := loadSnapshot(ctx, *to)
+	if err != nil {
+		log.Fatalf("failed to load snapshot %s: %v", *to, err)
+	}
+	report, err := snapshotdiff.Compare(*from, fromContent, *to, toContent)
+	if err != nil {
+		log.Fatalf("failed to compare snapshots: %v", err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Fatalf("failed to create output file: %v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if *format == formatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = report.WriteText(w)
+	}
+	if err != nil {
+		log.Fatalf("failed to write report: %v", err)
+	}
+}
+
+func loadSnapshot(ctx context.Context, snapshot string) (*wordpress.SiteContent, error) {
+	files, err := minioCl.BatchDownloadBytesWithPrefix(ctx, *minioBucket, snapshot)
+	if err != nil {
+		return nil, err
+	}
+	return wordpress.UnmarshalSiteContent(files)
+}
+
+func mustValidateConfig() {
+	if *from == "" {
+		log.Fatal("from is required")
+	}
+	if *to == "" {
+		log.Fatal("to is required")
+	}
+	if *format != formatText && *format != formatJSON {
+		log.Fatalf("unknown report format %q", *format)
+	}
+	if *minioEndpoint == "" {
+		log.Fatal("minio-endpoint is required")
+	}
+	if *minioRegion == "" {
+		log.Fatal("minio-region is required")
+	}
+	if *minioBucket == "" {
+		log.Fatal("minio-bucket is required")
+	}
+	if minioAccessKeyID == "" {
+		log.Fatalf("%s is required", minioAccessKeyIDEnv)
+	}
+	if minioSecretAccessKey == "" {
+		log.Fatalf("%s is required", minioSecretAccessKeyEnv)
+	}
+}
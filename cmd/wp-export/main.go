@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+
+	"github.com/ozansz/homelab-functions/pkg/minioext"
+	"github.com/ozansz/homelab-functions/pkg/staticexport"
+	"github.com/ozansz/homelab-functions/pkg/wordpress"
+	"github.com/ozansz/homelab-functions/pkg/wxr"
+)
+
+const (
+	minioAccessKeyIDEnv     = "MINIO_ACCESS_KEY_ID"
+	minioSecretAccessKeyEnv = "MINIO_SECRET_ACCESS_KEY"
+
+	formatWXR    = "wxr"
+	formatHugo   = string(staticexport.Hugo)
+	formatJekyll = string(staticexport.Jekyll)
+
+	wxrFile = "wxr.xml"
+)
+
+var (
+	url          = flag.String("url", "", "URL of the WordPress site")
+	siteTitle    = flag.String("site-title", "", "Title of the WordPress site (default the URL)")
+	snapshot     = flag.String("snapshot", "", "Path prefix of the snapshot to export, e.g. 2023/04/01/03/00 (default crawls the site)")
+	format       = flag.String("format", formatWXR, "Export format: wxr, hugo or jekyll")
+	output       = flag.String("output", "", "File to write the WXR export to, - for stdout, or directory to write the static site to (default uploads it into the snapshot)")
+	mediaBaseURL = flag.String("media-base-url", "", "Base URL the bucket's objects are served from, to point WXR attachments to the archived media files instead of the crawled site")
+	exportMedia  = flag.Bool("media", false, "Copy the media files into the static site, from the snapshot if they were archived")
+	httpTimeout  = flag.Duration("http-timeout", 10*time.Second, "Timeout for HTTP requests")
+	perPage      = flag.Int("per-page", 100, "Number of entities to fetch per page when crawling (max 100)")
+	concurrency  = flag.Int("concurrency", 4, "Number of pages to fetch in parallel when crawling")
+	cacheDir     = flag.String("cache-dir", "", "Directory to cache HTTP responses in when crawling, revalidated with the site on later runs")
+	offline      = flag.Bool("offline", false, "Crawl from the cache directory only, without contacting the site")
+
+	minioEndpoint    = flag.String("minio-endpoint", "", "Minio endpoint")
+	minioRegion      = flag.String("minio-region", "", "Minio region")
+	minioBucket      = flag.String("minio-bucket", "", "Minio bucket")
+	minioHTTPTimeout = flag.Duration("minio-http-timeout", 10*time.Second, "Timeout for Minio HTTP requests")
+
+	minioAccessKeyID     string
+	minioSecretAccessKey string
+	minioCl              *minioext.Client
+)
+
+func main() {
+	flag.Parse()
+	minioAccessKeyID = os.Getenv(minioAccessKeyIDEnv)
+	minioSecretAccessKey = os.Getenv(minioSecretAccessKeyEnv)
+
+	mustValidateConfig()
+
+	ctx := context.Background()
+
+	wpOpts := []wordpress.NewClientOpt{
+		wordpress.WithTimeout(*httpTimeout),
+		wordpress.WithPerPage(*perPage),
+		wordpress.WithConcurrency(*concurrency),
+	}
+	if *cacheDir != "" {
+		wpOpts = append(wpOpts, wordpress.WithCache(*cacheDir))
+	}
+	if *offline {
+		wpOpts = append(wpOpts, wordpress.WithOffline())
+	}
+	wpCl := wordpress.NewClient(*url, wpOpts...)
+
+	var err error
+	if needsMinio() {
+		minioCl, err = minioext.NewClient(*minioEndpoint, *minioRegion, minioext.WithTimeout(*minioHTTPTimeout), minioext.WithCredentials(minioAccessKeyID, minioSecretAccessKey))
+		if err != nil {
+			log.Fatalf("failed to create minio client: %v", err)
+		}
+	}
+
+	var (
+		content *wordpress.SiteContent
+		files   = make(map[string][]byte)
+	)
+	if *snapshot != "" {
+		if files, err = minioCl.BatchDownloadBytesWithPrefix(ctx, *minioBucket, *snapshot); err != nil {
+			log.Fatalf("failed to download snapshot: %v", err)
+		}
+		if content, err = wordpress.UnmarshalSiteContent(files); err != nil {
+			log.Fatalf("failed to unmarshal snapshot: %v", err)
+		}
+	} else if content, err = wpCl.GetAll(ctx); err != nil {
+		log.Fatalf("failed to get all data from WordPress: %v", err)
+	}
+	objects, err := wordpress.UnmarshalMediaObjects(files)
+	if err != nil {
+		log.Fatalf("failed to read media objects: %v", err)
+	}
+	archived := objects.BySourceURL()
+
+	if *format == formatWXR {
+		err = writeWXR(ctx, content, archived)
+	} else {
+		err = writeStaticSite(ctx, wpCl, content, archived)
+	}
+	if err != nil {
+		log.Fatalf("failed to export %s: %v", *format, err)
+	}
+
+	log.Println("ok!")
+}
+
+func writeWXR(ctx context.Context, content *wordpress.SiteContent, archived map[string]wordpress.ArchivedFile) error {
+	opts := wxr.Options{SiteURL: *url, SiteTitle: *siteTitle}
+	if opts.SiteTitle == "" {
+		opts.SiteTitle = *url
+	}
+	if *mediaBaseURL != "" {
+		if len(archived) == 0 {
+			return fmt.Errorf("snapshot has no %s, it was crawled without media", wordpress.MediaObjectsFile)
+		}
+		base := strings.TrimSuffix(*mediaBaseURL, "/")
+		opts.AttachmentURL = func(m wordpress.Media) string {
+			if f, ok := archived[m.SourceURL]; ok {
+				return base + "/" + f.Key
+			}
+			return m.SourceURL
+		}
+	}
+	var buf bytes.Buffer
+	if err := wxr.Write(&buf, content, opts); err != nil {
+		return err
+	}
+
+	switch *output {
+	case "":
+		return minioCl.UploadBytes(ctx, *minioBucket, snapshotObjectName(wxrFile), buf.Bytes(), minio.PutObjectOptions{
+			ContentType: "application/xml",
+		})
+	case "-":
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return os.WriteFile(*output, buf.Bytes(), 0o644)
+}
+
+func writeStaticSite(ctx context.Context, wpCl *wordpress.Client, content *wordpress.SiteContent, archived map[string]wordpress.ArchivedFile) error {
+	site, err := staticexport.Export(content, staticexport.Options{
+		Flavor:  staticexport.Flavor(*format),
+		SiteURL: *url,
+	})
+	if err != nil {
+		return err
+	}
+	for name, b := range site.Files {
+		if err := writeFile(ctx, name, bytes.NewReader(b), int64(len(b)), "text/markdown"); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	if !*exportMedia {
+		return nil
+	}
+	for name, sourceURL := range site.Media {
+		if err := copyMedia(ctx, wpCl, name, sourceURL, archived); err != nil {
+			// A missing image should not fail the whole export.
+			log.Printf("failed to copy media file %s: %v", sourceURL, err)
+		}
+	}
+	return nil
+}
+
+// Copies the media file from the snapshot if it was archived, otherwise
+// downloads it from the site.
+func copyMedia(ctx context.Context, wpCl *wordpress.Client, name, sourceURL string, archived map[string]wordpress.ArchivedFile) error {
+	var (
+		r    io.ReadCloser
+		size int64 = -1
+	)
+	if f, ok := archived[sourceURL]; ok {
+		obj, err := minioCl.DownloadReader(ctx, *minioBucket, f.Key)
+		if err != nil {
+			return err
+		}
+		r = obj
+	} else {
+		res, err := wpCl.Download(ctx, sourceURL)
+		if err != nil {
+			return err
+		}
+		r, size = res.Body, res.ContentLength
+	}
+	defer r.Close()
+	return writeFile(ctx, name, r, size, "")
+}
+
+// Writes the file of the static site into the output directory, or the
+// snapshot if there is none.
+func writeFile(ctx context.Context, name string, r io.Reader, size int64, contentType string) error {
+	if *output == "" {
+		return minioCl.UploadReader(ctx, *minioBucket, snapshotObjectName(*format+"/"+name), r, size, minio.PutObjectOptions{
+			ContentType: contentType,
+		})
+	}
+	p := filepath.Join(*output, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func snapshotObjectName(name string) string {
+	return fmt.Sprintf("%s/%s", strings.TrimSuffix(*snapshot, "/"), name)
+}
+
+func needsMinio() bool {
+	return *snapshot != "" || *output == ""
+}
+
+func mustValidateConfig() {
+	if *url == "" {
+		log.Fatal("url is required")
+	}
+	switch *format {
+	case formatWXR, formatHugo, formatJekyll:
+	default:
+		log.Fatalf("unknown export format %q", *format)
+	}
+	if *output == "" && *snapshot == "" {
+		log.Fatal("output is required when exporting a live crawl")
+	}
+	if *output == "-" && *format != formatWXR {
+		log.Fatal("static sites cannot be written to stdout")
+	}
+	if *offline && *cacheDir == "" {
+		log.Fatal("offline requires cache-dir")
+	}
+	if *mediaBaseURL != "" && *snapshot == "" {
+		log.Fatal("media-base-url requires a snapshot")
+	}
+	if needsMinio() {
+		if *minioEndpoint == "" {
+			log.Fatal("minio-endpoint is required")
+		}
+		if *minioRegion == "" {
+			log.Fatal("minio-region is required")
+		}
+		if *minioBucket == "" {
+			log.Fatal("minio-bucket is required")
+		}
+		if minioAccessKeyID == "" {
+			log.Fatalf("%s is required", minioAccessKeyIDEnv)
+		}
+		if minioSecretAccessKey == "" {
+			log.Fatalf("%s is required", minioSecretAccessKeyEnv)
+		}
+	}
+}
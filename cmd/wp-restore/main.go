@@ -0,0 +1,639 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"log"
+	neturl "net/url"
+	"os"
+	"path"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+
+	"github.com/ozansz/homelab-functions/pkg/minioext"
+	"github.com/ozansz/homelab-functions/pkg/wordpress"
+)
+
+const (
+	minioAccessKeyIDEnv     = "MINIO_ACCESS_KEY_ID"
+	minioSecretAccessKeyEnv = "MINIO_SECRET_ACCESS_KEY"
+
+	wpAppPasswordEnv = "WP_APP_PASSWORD"
+	wpBearerTokenEnv = "WP_BEARER_TOKEN"
+	wpCookieEnv      = "WP_COOKIE"
+	wpNonceEnv       = "WP_NONCE"
+)
+
+var (
+	url           = flag.String("url", "", "URL of the WordPress site to restore into")
+	snapshot      = flag.String("snapshot", "", "Path prefix of the snapshot to restore, e.g. 2023/04/01/03/00")
+	stateObject   = flag.String("state-object", "", "Minio object name of the restore state (default restore/<host>.json, which only restores the snapshot it was created for)")
+	defaultAuthor = flag.Int("default-author", 0, "ID of the user on the target site that gets the content of users that cannot be restored, 0 for the authenticated user")
+	httpTimeout   = flag.Duration("http-timeout", 30*time.Second, "Timeout for HTTP requests")
+	retries       = flag.Int("retries", 5, "Number of retries of requests failing with a transient error")
+
+	wpUser            = flag.String("wp-user", "", "WordPress username to authenticate with an application password")
+	wpAppPasswordFile = flag.String("wp-app-password-file", "", "File containing the WordPress application password (default $"+wpAppPasswordEnv+")")
+	wpBearerTokenFile = flag.String("wp-bearer-token-file", "", "File containing a bearer/JWT token (default $"+wpBearerTokenEnv+")")
+	wpCookieFile      = flag.String("wp-cookie-file", "", "File containing a logged in user's cookie (default $"+wpCookieEnv+")")
+	wpNonceFile       = flag.String("wp-nonce-file", "", "File containing the wp_rest nonce for the cookie (default $"+wpNonceEnv+")")
+
+	minioEndpoint    = flag.String("minio-endpoint", "", "Minio endpoint")
+	minioRegion      = flag.String("minio-region", "", "Minio region")
+	minioBucket      = flag.String("minio-bucket", "", "Minio bucket")
+	minioHTTPTimeout = flag.Duration("minio-http-timeout", 10*time.Second, "Timeout for Minio HTTP requests")
+
+	minioAccessKeyID     string
+	minioSecretAccessKey string
+	minioCl              *minioext.Client
+)
+
+func main() {
+	flag.Parse()
+	minioAccessKeyID = os.Getenv(minioAccessKeyIDEnv)
+	minioSecretAccessKey = os.Getenv(minioSecretAccessKeyEnv)
+
+	mustValidateConfig()
+
+	ctx := context.Background()
+
+	authOpts, err := wpAuthOpts()
+	if err != nil {
+		log.Fatalf("failed to read WordPress credentials: %v", err)
+	}
+	if len(authOpts) == 0 {
+		log.Fatal("restoring requires WordPress credentials")
+	}
+	wpCl := wordpress.NewClient(*url, append([]wordpress.NewClientOpt{
+		wordpress.WithTimeout(*httpTimeout),
+		wordpress.WithRetries(*retries, time.Second, time.Minute),
+	}, authOpts...)...)
+
+	minioCl, err = minioext.NewClient(*minioEndpoint, *minioRegion, minioext.WithTimeout(*minioHTTPTimeout), minioext.WithCredentials(minioAccessKeyID, minioSecretAccessKey))
+	if err != nil {
+		log.Fatalf("failed to create minio client: %v", err)
+	}
+
+	files, err := minioCl.BatchDownloadBytesWithPrefix(ctx, *minioBucket, *snapshot)
+	if err != nil {
+		log.Fatalf("failed to download snapshot: %v", err)
+	}
+	content, err := wordpress.UnmarshalSiteContent(files)
+	if err != nil {
+		log.Fatalf("failed to unmarshal snapshot: %v", err)
+	}
+	mediaObjects, err := wordpress.UnmarshalMediaObjects(files)
+	if err != nil {
+		log.Fatalf("failed to read media objects: %v", err)
+	}
+
+	state, err := loadState(ctx)
+	if err != nil {
+		log.Fatalf("failed to load restore state: %v", err)
+	}
+	// The IDs of another snapshot may belong to another source site, whose
+	// entities would overwrite unrelated ones on the target site.
+	if state.Snapshot != "" && state.Snapshot != *snapshot && *stateObject == "" {
+		log.Fatalf("%s is the restore state of snapshot %s, pass -state-object to restore %s with it or with a new state", stateObjectName(), state.Snapshot, *snapshot)
+	}
+	state.Snapshot = *snapshot
+
+	r := &restorer{
+		cl:           wpCl,
+		content:      content,
+		mediaObjects: mediaObjects,
+		state:        state,
+	}
+	for _, step := range []struct {
+		name string
+		fn   func(context.Context) error
+	}{
+		{"users", r.restoreUsers},
+		{"categories", r.restoreCategories},
+		{"tags", r.restoreTags},
+		{"media", r.restoreMedia},
+		{"pages", r.restorePages},
+		{"posts", r.restorePosts},
+		{"media attachments", r.attachMedia},
+		{"comments", r.restoreComments},
+	} {
+		log.Printf("restoring %s", step.name)
+		err := step.fn(ctx)
+		// Creates are saved as they happen, this saves the entities found by
+		// slug too, even on failure.
+		if saveErr := saveState(ctx, state); saveErr != nil {
+			log.Fatalf("failed to save restore state: %v", saveErr)
+		}
+		if err != nil {
+			log.Fatalf("failed to restore %s: %v", step.name, err)
+		}
+	}
+
+	log.Println("ok!")
+}
+
+// Maps the IDs of the snapshot's entities to the IDs of the restored
+// entities on the target site, per entity type.
+type restoreState struct {
+	Snapshot string                 `json:"snapshot"`
+	IDs      map[string]map[int]int `json:"ids"`
+}
+
+func (s *restoreState) get(entity string, id int) (int, bool) {
+	to, ok := s.IDs[entity][id]
+	return to, ok
+}
+
+func (s *restoreState) forget(entity string, id int) {
+	delete(s.IDs[entity], id)
+}
+
+func (s *restoreState) set(entity string, from, to int) {
+	if s.IDs[entity] == nil {
+		s.IDs[entity] = make(map[int]int)
+	}
+	s.IDs[entity][from] = to
+}
+
+type restorer struct {
+	cl           *wordpress.Client
+	content      *wordpress.SiteContent
+	mediaObjects wordpress.MediaObjects
+	state        *restoreState
+}
+
+// Returns the ID of the restored entity, 0 if it was not restored.
+func (r *restorer) mapped(entity string, id int) int {
+	to, _ := r.state.get(entity, id)
+	return to
+}
+
+func (r *restorer) mappedAll(entity string, ids []int) []int {
+	var mapped []int
+	for _, id := range ids {
+		if to, ok := r.state.get(entity, id); ok {
+			mapped = append(mapped, to)
+		}
+	}
+	return mapped
+}
+
+func (r *restorer) author(id int) int {
+	if to, ok := r.state.get(wordpress.EntityUsers, id); ok {
+		return to
+	}
+	return *defaultAuthor
+}
+
+// Creates the entity, unless it was restored before or an entity with the
+// same slug exists on the target site, in which case that entity is updated
+// instead. Records the ID mapping. Entities without a slug, i.e. comments,
+// are only found through the state. Entities restored before but deleted on
+// the target site since are restored again.
+func (r *restorer) upsert(ctx context.Context, entity string, id int, slug string, in any) error {
+	endpoint := wordpress.EntityEndpoint(entity)
+	if to, ok := r.state.get(entity, id); ok {
+		_, err := wordpress.Update[json.RawMessage](ctx, r.cl, endpoint, to, in)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, wordpress.ErrNotFound) {
+			return fmt.Errorf("failed to update %s %d: %w", entity, id, err)
+		}
+		log.Printf("%s %d was restored as %d, which no longer exists, restoring it again", entity, id, to)
+		r.state.forget(entity, id)
+	}
+	if slug != "" {
+		found, err := r.findBySlug(ctx, entity, slug)
+		if err != nil {
+			return err
+		}
+		if found != 0 {
+			if _, err := wordpress.Update[json.RawMessage](ctx, r.cl, endpoint, found, in); err != nil {
+				return fmt.Errorf("failed to update %s %d: %w", entity, id, err)
+			}
+			r.state.set(entity, id, found)
+			return nil
+		}
+	}
+	created, err := wordpress.Create[idOnly](ctx, r.cl, endpoint, in)
+	if err != nil {
+		return fmt.Errorf("failed to create %s %d: %w", entity, id, err)
+	}
+	return r.created(ctx, entity, id, created.ID)
+}
+
+// Records the ID of a created entity and saves the state right away, so that
+// a re-run after a failure does not create the entity again.
+func (r *restorer) created(ctx context.Context, entity string, from, to int) error {
+	r.state.set(entity, from, to)
+	if err := saveState(ctx, r.state); err != nil {
+		return fmt.Errorf("failed to save restore state: %w", err)
+	}
+	return nil
+}
+
+type idOnly struct {
+	ID int `json:"id"`
+}
+
+// Returns the ID of the entity with the slug on the target site, 0 if there
+// is none.
+func (r *restorer) findBySlug(ctx context.Context, entity, slug string) (int, error) {
+	opts := &wordpress.ListOptions{
+		Filters: neturl.Values{"slug": []string{slug}},
+		Fields:  []string{"id"},
+	}
+	switch entity {
+	case wordpress.EntityPosts, wordpress.EntityPages:
+		opts.Status = []string{"any"}
+	}
+	found, err := wordpress.List[idOnly](ctx, r.cl, wordpress.EntityEndpoint(entity), opts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up %s %s: %w", entity, slug, err)
+	}
+	if len(found) == 0 {
+		return 0, nil
+	}
+	return found[0].ID, nil
+}
+
+// Users are only created, never updated, so that restoring does not change
+// e.g. the email of an existing administrator.
+func (r *restorer) restoreUsers(ctx context.Context) error {
+	for _, u := range r.content.Users {
+		if _, ok := r.state.get(wordpress.EntityUsers, u.ID); ok {
+			continue
+		}
+		found, err := r.findBySlug(ctx, wordpress.EntityUsers, u.Slug)
+		if err != nil {
+			return err
+		}
+		if found != 0 {
+			r.state.set(wordpress.EntityUsers, u.ID, found)
+			continue
+		}
+		if u.Username == "" || u.Email == "" {
+			log.Printf("cannot create user %s without a username and email, the snapshot was not taken with the edit context", u.Slug)
+			continue
+		}
+		created, err := r.cl.CreateUser(ctx, wordpress.UserInput{
+			Username:    u.Username,
+			Name:        u.Name,
+			FirstName:   u.FirstName,
+			LastName:    u.LastName,
+			Email:       u.Email,
+			URL:         u.URL,
+			Description: u.Description,
+			Locale:      u.Locale,
+			Nickname:    u.Nickname,
+			Slug:        u.Slug,
+			Roles:       u.Roles,
+			// Users have to reset their password after a restore.
+			Password: randomPassword(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create user %d: %w", u.ID, err)
+		}
+		if err := r.created(ctx, wordpress.EntityUsers, u.ID, created.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *restorer) restoreCategories(ctx context.Context) error {
+	categories := parentsFirst(r.content.Categories,
+		func(c wordpress.Category) int { return c.ID },
+		func(c wordpress.Category) int { return c.Parent })
+	for _, c := range categories {
+		if err := r.upsert(ctx, wordpress.EntityCategories, c.ID, c.Slug, wordpress.CategoryInput{
+			Name:        html.UnescapeString(c.Name),
+			Slug:        c.Slug,
+			Description: c.Description,
+			Parent:      r.mapped(wordpress.EntityCategories, c.Parent),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *restorer) restoreTags(ctx context.Context) error {
+	for _, t := range r.content.Tags {
+		if err := r.upsert(ctx, wordpress.EntityTags, t.ID, t.Slug, wordpress.TagInput{
+			Name:        html.UnescapeString(t.Name),
+			Slug:        t.Slug,
+			Description: t.Description,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Uploads the archived original files. Media without an archived file, e.g.
+// because the snapshot was taken without -media, cannot be restored.
+func (r *restorer) restoreMedia(ctx context.Context) error {
+	for _, m := range r.content.Media {
+		in := wordpress.MediaInput{
+			DateGMT:     m.DateGMT,
+			Slug:        m.Slug,
+			Title:       m.Title.Text(),
+			Caption:     raw(m.Caption),
+			Description: raw(m.Description),
+			AltText:     m.AltText,
+			Author:      r.author(m.Author),
+		}
+		if id, ok := r.state.get(wordpress.EntityMedia, m.ID); ok {
+			_, err := r.cl.UpdateMedia(ctx, id, in)
+			if err == nil {
+				continue
+			}
+			if !errors.Is(err, wordpress.ErrNotFound) {
+				return fmt.Errorf("failed to update media %d: %w", m.ID, err)
+			}
+			log.Printf("media %d was restored as %d, which no longer exists, restoring it again", m.ID, id)
+			r.state.forget(wordpress.EntityMedia, m.ID)
+		}
+		id, err := r.findBySlug(ctx, wordpress.EntityMedia, m.Slug)
+		if err != nil {
+			return err
+		}
+		if id == 0 {
+			original := r.mediaObjects[m.ID]["original"]
+			if original.Key == "" || original.Error != "" {
+				log.Printf("cannot restore media %d, there is no archived file", m.ID)
+				continue
+			}
+			created, err := r.uploadMedia(ctx, original.Key, m.MimeType)
+			if err != nil {
+				return fmt.Errorf("failed to upload media %d: %w", m.ID, err)
+			}
+			if err := r.created(ctx, wordpress.EntityMedia, m.ID, created.ID); err != nil {
+				return err
+			}
+			id = created.ID
+		}
+		if _, err := r.cl.UpdateMedia(ctx, id, in); err != nil {
+			return fmt.Errorf("failed to update media %d: %w", m.ID, err)
+		}
+		r.state.set(wordpress.EntityMedia, m.ID, id)
+	}
+	return nil
+}
+
+func (r *restorer) uploadMedia(ctx context.Context, key, mimeType string) (*wordpress.Media, error) {
+	obj, err := minioCl.DownloadReader(ctx, *minioBucket, key)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	if mimeType == "" {
+		info, err := obj.Stat()
+		if err != nil {
+			return nil, err
+		}
+		mimeType = info.ContentType
+	}
+	return r.cl.UploadMedia(ctx, path.Base(key), mimeType, obj)
+}
+
+func (r *restorer) restorePages(ctx context.Context) error {
+	pages := parentsFirst(r.content.Pages,
+		func(p wordpress.Page) int { return p.ID },
+		func(p wordpress.Page) int { return p.Parent })
+	for _, p := range pages {
+		if err := r.upsert(ctx, wordpress.EntityPages, p.ID, p.Slug, wordpress.PageInput{
+			DateGMT:  p.DateGMT,
+			Slug:     p.Slug,
+			Status:   p.Status,
+			Password: p.Password,
+			Title:    p.PlainTitle(),
+			Content:  raw(p.Content),
+			Excerpt:  raw(p.Excerpt),
+			Author:   r.author(p.Author),
+			Parent:   r.mapped(wordpress.EntityPages, p.Parent),
+
+			FeaturedMedia: r.mapped(wordpress.EntityMedia, p.FeaturedMedia),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *restorer) restorePosts(ctx context.Context) error {
+	for _, p := range r.content.Posts {
+		if err := r.upsert(ctx, wordpress.EntityPosts, p.ID, p.Slug, wordpress.PostInput{
+			DateGMT:       p.DateGMT,
+			Slug:          p.Slug,
+			Status:        p.Status,
+			Password:      p.Password,
+			Title:         p.PlainTitle(),
+			Content:       raw(p.Content),
+			Excerpt:       raw(p.Excerpt),
+			Author:        r.author(p.Author),
+			FeaturedMedia: r.mapped(wordpress.EntityMedia, p.FeaturedMedia),
+			Categories:    r.mappedAll(wordpress.EntityCategories, p.Categories),
+			Tags:          r.mappedAll(wordpress.EntityTags, p.Tags),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Attaches the restored media to their restored parent posts or pages, which
+// do not exist yet when the media is uploaded.
+func (r *restorer) attachMedia(ctx context.Context) error {
+	for _, m := range r.content.Media {
+		id, ok := r.state.get(wordpress.EntityMedia, m.ID)
+		if !ok || m.Post == 0 {
+			continue
+		}
+		parent, ok := r.state.get(wordpress.EntityPosts, m.Post)
+		if !ok {
+			parent, ok = r.state.get(wordpress.EntityPages, m.Post)
+		}
+		if !ok {
+			continue
+		}
+		if _, err := r.cl.UpdateMedia(ctx, id, wordpress.MediaInput{Post: parent}); err != nil {
+			return fmt.Errorf("failed to attach media %d: %w", m.ID, err)
+		}
+	}
+	return nil
+}
+
+func (r *restorer) restoreComments(ctx context.Context) error {
+	comments := parentsFirst(r.content.Comments,
+		func(c wordpress.Comment) int { return c.ID },
+		func(c wordpress.Comment) int { return c.Parent })
+	for _, c := range comments {
+		post, ok := r.state.get(wordpress.EntityPosts, c.Post)
+		if !ok {
+			post, ok = r.state.get(wordpress.EntityPages, c.Post)
+		}
+		if !ok {
+			log.Printf("cannot restore comment %d, post %d was not restored", c.ID, c.Post)
+			continue
+		}
+		if err := r.upsert(ctx, wordpress.EntityComments, c.ID, "", wordpress.CommentInput{
+			Post:        post,
+			Parent:      r.mapped(wordpress.EntityComments, c.Parent),
+			Author:      r.mapped(wordpress.EntityUsers, c.Author),
+			AuthorName:  c.AuthorName,
+			AuthorEmail: c.AuthorEmail,
+			AuthorURL:   c.AuthorURL,
+			AuthorIP:    c.AuthorIP,
+			Content:     raw(c.Content),
+			DateGMT:     c.DateGMT,
+			Status:      c.Status,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Orders the entities so that parents come before their children. Entities
+// with a parent that is not in the list are treated as roots, and so are the
+// entities of a parent cycle, in their original order.
+func parentsFirst[T any](entities []T, id, parent func(T) int) []T {
+	ids := make(map[int]bool, len(entities))
+	for _, e := range entities {
+		ids[id(e)] = true
+	}
+	var queue []T
+	children := make(map[int][]T)
+	for _, e := range entities {
+		if p := parent(e); p != 0 && p != id(e) && ids[p] {
+			children[p] = append(children[p], e)
+		} else {
+			queue = append(queue, e)
+		}
+	}
+	ordered := make([]T, 0, len(entities))
+	visited := make(map[int]bool, len(entities))
+	walk := func() {
+		for len(queue) > 0 {
+			e := queue[0]
+			queue = queue[1:]
+			if visited[id(e)] {
+				continue
+			}
+			visited[id(e)] = true
+			ordered = append(ordered, e)
+			queue = append(queue, children[id(e)]...)
+		}
+	}
+	walk()
+	for _, e := range entities {
+		if !visited[id(e)] {
+			queue = append(queue, e)
+			walk()
+		}
+	}
+	return ordered
+}
+
+// Prefers the raw field, which is only captured with the edit context.
+func raw(f wordpress.RenderedField) string {
+	if f.Raw != "" {
+		return f.Raw
+	}
+	return f.Rendered
+}
+
+func randomPassword() string {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatalf("failed to generate password: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func loadState(ctx context.Context) (*restoreState, error) {
+	state := &restoreState{IDs: make(map[string]map[int]int)}
+	b, err := minioCl.DownloadBytes(ctx, *minioBucket, stateObjectName())
+	if minioext.IsNotFound(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, err
+	}
+	if state.IDs == nil {
+		state.IDs = make(map[string]map[int]int)
+	}
+	return state, nil
+}
+
+func saveState(ctx context.Context, state *restoreState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return minioCl.UploadBytes(ctx, *minioBucket, stateObjectName(), b, minio.PutObjectOptions{
+		ContentType: "application/json",
+	})
+}
+
+func stateObjectName() string {
+	if *stateObject != "" {
+		return *stateObject
+	}
+	host := *url
+	if u, err := neturl.Parse(*url); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	return fmt.Sprintf("restore/%s.json", host)
+}
+
+func wpAuthOpts() ([]wordpress.NewClientOpt, error) {
+	creds, err := wordpress.CredentialSources{
+		Username:    *wpUser,
+		AppPassword: wordpress.Secret{Env: wpAppPasswordEnv, File: *wpAppPasswordFile},
+		BearerToken: wordpress.Secret{Env: wpBearerTokenEnv, File: *wpBearerTokenFile},
+		Cookie:      wordpress.Secret{Env: wpCookieEnv, File: *wpCookieFile},
+		Nonce:       wordpress.Secret{Env: wpNonceEnv, File: *wpNonceFile},
+	}.Read()
+	if err != nil {
+		return nil, err
+	}
+	return creds.Options()
+}
+
+func mustValidateConfig() {
+	if *url == "" {
+		log.Fatal("url is required")
+	}
+	if *snapshot == "" {
+		log.Fatal("snapshot is required")
+	}
+	if *minioEndpoint == "" {
+		log.Fatal("minio-endpoint is required")
+	}
+	if *minioRegion == "" {
+		log.Fatal("minio-region is required")
+	}
+	if *minioBucket == "" {
+		log.Fatal("minio-bucket is required")
+	}
+	if minioAccessKeyID == "" {
+		log.Fatalf("%s is required", minioAccessKeyIDEnv)
+	}
+	if minioSecretAccessKey == "" {
+		log.Fatalf("%s is required", minioSecretAccessKeyEnv)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type node struct {
+	id, parent int
+}
+
+func TestParentsFirst(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		entities []node
+		want     []int
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:     "children before parents",
+			entities: []node{{3, 2}, {2, 1}, {1, 0}},
+			want:     []int{1, 2, 3},
+		},
+		{
+			name:     "siblings keep their order",
+			entities: []node{{4, 1}, {1, 0}, {2, 1}, {3, 0}},
+			want:     []int{1, 3, 4, 2},
+		},
+		{
+			name:     "missing parent is a root",
+			entities: []node{{2, 9}, {1, 2}},
+			want:     []int{2, 1},
+		},
+		{
+			name:     "own parent is a root",
+			entities: []node{{1, 1}, {2, 1}},
+			want:     []int{1, 2},
+		},
+		{
+			name:     "cycle is kept",
+			entities: []node{{1, 0}, {2, 3}, {3, 2}, {4, 3}},
+			want:     []int{1, 2, 3, 4},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ordered := parentsFirst(tc.entities,
+				func(n node) int { return n.id },
+				func(n node) int { return n.parent })
+			var got []int
+			for _, n := range ordered {
+				got = append(got, n.id)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("parentsFirst() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
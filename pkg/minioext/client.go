@@ -3,9 +3,11 @@ package minioext
 import (
 	"context"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/minio/minio-go/v7"
@@ -21,6 +23,14 @@ const (
 	LayoutYYYYMMDD       DateTimePathLayout = "2006/01/02"
 )
 
+// Part size of streamed uploads. minio-go would otherwise size the parts for
+// the largest possible object and buffer 512 MiB per part.
+const streamPartSize = 16 << 20
+
+func (l DateTimePathLayout) Format(t time.Time) string {
+	return t.Format(string(l))
+}
+
 type Client struct {
 	cl     *minio.Client
 	region string
@@ -112,6 +122,62 @@ func (cl *Client) UploadBytes(ctx context.Context, bucket, objectName string, da
 	return err
 }
 
+// Uploads the data read from r. Pass -1 as the size if it is not known in
+// advance, the object is then uploaded in parts.
+func (cl *Client) UploadReader(ctx context.Context, bucket, objectName string, r io.Reader, size int64, opts minio.PutObjectOptions) error {
+	if _, err := cl.CreateBucketIfNotExists(ctx, bucket); err != nil {
+		return err
+	}
+	_, err := cl.cl.PutObject(ctx, bucket, objectName, r, size, opts)
+	return err
+}
+
+// Uploads the data written to the returned writer in parts, without
+// buffering the whole object. Close finishes the upload and returns its
+// error, CloseWithError aborts it.
+func (cl *Client) NewObjectWriter(ctx context.Context, bucket, objectName string, opts minio.PutObjectOptions) (*ObjectWriter, error) {
+	if _, err := cl.CreateBucketIfNotExists(ctx, bucket); err != nil {
+		return nil, err
+	}
+	if opts.PartSize == 0 {
+		opts.PartSize = streamPartSize
+	}
+	pr, pw := io.Pipe()
+	w := &ObjectWriter{pw: pw, done: make(chan error, 1)}
+	go func() {
+		_, err := cl.cl.PutObject(ctx, bucket, objectName, pr, -1, opts)
+		// Unblock the writer if the upload stopped reading early.
+		if err != nil {
+			pr.CloseWithError(err)
+		} else {
+			pr.Close()
+		}
+		w.done <- err
+	}()
+	return w, nil
+}
+
+type ObjectWriter struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+func (w *ObjectWriter) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+func (w *ObjectWriter) Close() error {
+	w.pw.Close()
+	return <-w.done
+}
+
+// Aborts the upload. The incomplete object is not stored.
+func (w *ObjectWriter) CloseWithError(err error) error {
+	w.pw.CloseWithError(err)
+	<-w.done
+	return err
+}
+
 func (cl *Client) UploadBytesWithDatePath(ctx context.Context, bucket, objectName string, data []byte, opts minio.PutObjectOptions) error {
 	now := time.Now().UTC()
 	path := fmt.Sprintf("%s/%s", now.Format("2006/01/02"), objectName)
@@ -120,9 +186,12 @@ func (cl *Client) UploadBytesWithDatePath(ctx context.Context, bucket, objectNam
 }
 
 func (cl *Client) BatchUploadBytesWithDateTimePath(ctx context.Context, bucket string, objects map[string][]byte, layout DateTimePathLayout, opts minio.PutObjectOptions) error {
-	now := time.Now().UTC()
+	return cl.BatchUploadBytesWithPrefix(ctx, bucket, layout.Format(time.Now().UTC()), objects, opts)
+}
+
+func (cl *Client) BatchUploadBytesWithPrefix(ctx context.Context, bucket, prefix string, objects map[string][]byte, opts minio.PutObjectOptions) error {
 	for objectName, data := range objects {
-		path := fmt.Sprintf("%s/%s", now.Format(string(layout)), objectName)
+		path := fmt.Sprintf("%s/%s", prefix, objectName)
 		if err := cl.UploadBytes(ctx, bucket, path, data, opts); err != nil {
 			return fmt.Errorf("failed to upload %s: %w", objectName, err)
 		}
@@ -130,6 +199,75 @@ func (cl *Client) BatchUploadBytesWithDateTimePath(ctx context.Context, bucket s
 	return nil
 }
 
+// Copies an object within the bucket on the server, without downloading it.
+// The source must not be larger than 5 GiB.
+func (cl *Client) CopyObject(ctx context.Context, bucket, src, dst string) error {
+	_, err := cl.cl.CopyObject(ctx, minio.CopyDestOptions{Bucket: bucket, Object: dst}, minio.CopySrcOptions{Bucket: bucket, Object: src})
+	return err
+}
+
+func (cl *Client) DownloadBytes(ctx context.Context, bucket, objectName string) ([]byte, error) {
+	obj, err := cl.cl.GetObject(ctx, bucket, objectName, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	return io.ReadAll(obj)
+}
+
+// Returns the object for streaming reads. The caller must close it.
+func (cl *Client) DownloadReader(ctx context.Context, bucket, objectName string) (*minio.Object, error) {
+	return cl.cl.GetObject(ctx, bucket, objectName, minio.GetObjectOptions{})
+}
+
+// Downloads every object directly under the given prefix. Objects in nested
+// "directories" are skipped. The returned map is keyed by the object name
+// relative to the prefix.
+func (cl *Client) BatchDownloadBytesWithPrefix(ctx context.Context, bucket, prefix string) (map[string][]byte, error) {
+	prefix = strings.TrimSuffix(prefix, "/") + "/"
+	objects := make(map[string][]byte)
+	for info := range cl.cl.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if info.Err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", info.Err)
+		}
+		if strings.HasSuffix(info.Key, "/") {
+			continue
+		}
+		data, err := cl.DownloadBytes(ctx, bucket, info.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download %s: %w", info.Key, err)
+		}
+		objects[strings.TrimPrefix(info.Key, prefix)] = data
+	}
+	return objects, nil
+}
+
+// Returns the names of all objects under the given prefix, including the
+// ones in nested "directories".
+func (cl *Client) ListObjectNames(ctx context.Context, bucket, prefix string) ([]string, error) {
+	var names []string
+	for info := range cl.cl.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if info.Err != nil {
+			if IsNotFound(info.Err) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("failed to list objects: %w", info.Err)
+		}
+		names = append(names, info.Key)
+	}
+	return names, nil
+}
+
+// Returns true if the error returned from a download means the object or the
+// bucket does not exist.
+func IsNotFound(err error) bool {
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey", "NoSuchBucket":
+		return true
+	}
+	return false
+}
+
 func defaultHTTPTransport() *http.Transport {
 	return &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
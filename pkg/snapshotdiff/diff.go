@@ -0,0 +1,368 @@
+// Package snapshotdiff compares two snapshots of a WordPress site.
+package snapshotdiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/ozansz/homelab-functions/pkg/wordpress"
+)
+
+const (
+	Created  = "created"
+	Deleted  = "deleted"
+	Modified = "modified"
+)
+
+type Report struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Keyed by entity type, e.g. "posts" or a custom collection endpoint.
+	// Entity types without changes are omitted.
+	Entities map[string][]Change `json:"entities"`
+	// Endpoints truncated by a crawl budget in the newer snapshot. Their
+	// missing entities are not reported as deleted.
+	Truncated []string `json:"truncated,omitempty"`
+	// Changes to the site fingerprint, e.g. installed plugins or routes.
+	// Only set if both snapshots have one.
+	Site []FieldDiff `json:"site,omitempty"`
+
+	truncated map[string]bool
+}
+
+type Change struct {
+	Kind  string `json:"kind"`
+	ID    int    `json:"id"`
+	Label string `json:"label,omitempty"`
+	// Only set for modified entities.
+	Fields []FieldDiff `json:"fields,omitempty"`
+}
+
+type FieldDiff struct {
+	// Go style path of the field, e.g. ".Title.Rendered", or
+	// `["title"]["rendered"]` for entities compared by their original JSON
+	// and custom collections.
+	Path string `json:"path"`
+	Old  any    `json:"old,omitempty"`
+	New  any    `json:"new,omitempty"`
+	// Unified diff of multi-line text fields, e.g. rendered content, which
+	// replaces Old and New.
+	Diff string `json:"diff,omitempty"`
+}
+
+// Compares two snapshots and reports the created, deleted and modified
+// entities of each type. The snapshot names are only used for the report.
+func Compare(fromName string, from *wordpress.SiteContent, toName string, to *wordpress.SiteContent) (*Report, error) {
+	r := &Report{From: fromName, To: toName, Entities: make(map[string][]Change), truncated: make(map[string]bool)}
+	for _, t := range to.Truncated {
+		r.Truncated = append(r.Truncated, t.Endpoint)
+		r.truncated[t.Endpoint] = true
+	}
+	if from.Site != nil && to.Site != nil {
+		x, err := siteFields(from.Site)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode site info of %s: %w", fromName, err)
+		}
+		y, err := siteFields(to.Site)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode site info of %s: %w", toName, err)
+		}
+		r.Site = diffFields(x, y)
+	}
+	r.add(wordpress.EntityCategories, compareEntities(from.Categories, to.Categories, func(e wordpress.Category) (int, string) { return e.ID, e.Slug }))
+	r.add(wordpress.EntityComments, compareEntities(from.Comments, to.Comments, func(e wordpress.Comment) (int, string) {
+		return e.ID, fmt.Sprintf("by %s on post %d", e.AuthorName, e.Post)
+	}))
+	r.add(wordpress.EntityMedia, compareEntities(from.Media, to.Media, func(e wordpress.Media) (int, string) { return e.ID, e.Slug }))
+	r.add(wordpress.EntityPages, compareEntities(from.Pages, to.Pages, func(e wordpress.Page) (int, string) { return e.ID, e.Slug }))
+	r.add(wordpress.EntityPosts, compareEntities(from.Posts, to.Posts, func(e wordpress.Post) (int, string) { return e.ID, e.Slug }))
+	r.add(wordpress.EntityTags, compareEntities(from.Tags, to.Tags, func(e wordpress.Tag) (int, string) { return e.ID, e.Slug }))
+	r.add(wordpress.EntityUsers, compareEntities(from.Users, to.Users, func(e wordpress.User) (int, string) { return e.ID, e.Slug }))
+
+	endpoints := make(map[string]bool)
+	for endpoint := range from.Extra {
+		endpoints[endpoint] = true
+	}
+	for endpoint := range to.Extra {
+		endpoints[endpoint] = true
+	}
+	for endpoint := range endpoints {
+		x, err := decodeExtra(from.Extra[endpoint])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s of %s: %w", endpoint, fromName, err)
+		}
+		y, err := decodeExtra(to.Extra[endpoint])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s of %s: %w", endpoint, toName, err)
+		}
+		r.add(endpoint, compareEntities(x, y, func(e map[string]any) (int, string) {
+			id, _ := e["id"].(float64)
+			slug, _ := e["slug"].(string)
+			return int(id), slug
+		}))
+	}
+	return r, nil
+}
+
+func (r *Report) add(entity string, changes []Change) {
+	if r.truncated[wordpress.EntityEndpoint(entity)] || r.truncated[entity] {
+		kept := changes[:0]
+		for _, c := range changes {
+			if c.Kind != Deleted {
+				kept = append(kept, c)
+			}
+		}
+		changes = kept
+	}
+	if len(changes) > 0 {
+		r.Entities[entity] = changes
+	}
+}
+
+// Writes the report in a human-readable form.
+func (r *Report) WriteText(w io.Writer) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "changes from %s to %s\n", r.From, r.To)
+	if len(r.Entities) == 0 && len(r.Site) == 0 {
+		sb.WriteString("no changes\n")
+	}
+	for _, endpoint := range r.Truncated {
+		fmt.Fprintf(&sb, "%s is truncated in %s, deletions are not reported\n", endpoint, r.To)
+	}
+	if len(r.Site) > 0 {
+		fmt.Fprintf(&sb, "\nsite: %d changes\n", len(r.Site))
+		writeFields(&sb, r.Site, "  ")
+	}
+	entities := make([]string, 0, len(r.Entities))
+	for entity := range r.Entities {
+		entities = append(entities, entity)
+	}
+	sort.Strings(entities)
+	for _, entity := range entities {
+		counts := make(map[string]int)
+		for _, c := range r.Entities[entity] {
+			counts[c.Kind]++
+		}
+		fmt.Fprintf(&sb, "\n%s: %d created, %d deleted, %d modified\n", entity, counts[Created], counts[Deleted], counts[Modified])
+		for _, c := range r.Entities[entity] {
+			fmt.Fprintf(&sb, "  %s %s %d %q\n", kindMarker(c.Kind), c.Kind, c.ID, c.Label)
+			writeFields(&sb, c.Fields, "      ")
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func writeFields(sb *strings.Builder, fields []FieldDiff, indent string) {
+	for _, f := range fields {
+		if f.Diff != "" {
+			fmt.Fprintf(sb, "%s%s:\n", indent, f.Path)
+			for _, l := range strings.Split(strings.TrimSuffix(f.Diff, "\n"), "\n") {
+				fmt.Fprintf(sb, "%s  %s\n", indent, l)
+			}
+			continue
+		}
+		fmt.Fprintf(sb, "%s%s: %s -> %s\n", indent, f.Path, formatValue(f.Old), formatValue(f.New))
+	}
+}
+
+func kindMarker(kind string) string {
+	switch kind {
+	case Created:
+		return "+"
+	case Deleted:
+		return "-"
+	}
+	return "~"
+}
+
+func formatValue(v any) string {
+	if v == nil {
+		return "<none>"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// Compares the entities by the ID returned by key, which also returns the
+// label shown in the report. Changes are ordered by ID.
+func compareEntities[T any](from, to []T, key func(T) (int, string)) []Change {
+	old := make(map[int]T, len(from))
+	for _, e := range from {
+		id, _ := key(e)
+		old[id] = e
+	}
+	var changes []Change
+	seen := make(map[int]bool, len(to))
+	for _, e := range to {
+		id, label := key(e)
+		seen[id] = true
+		prev, ok := old[id]
+		if !ok {
+			changes = append(changes, Change{Kind: Created, ID: id, Label: label})
+			continue
+		}
+		if fields := diffFields(prev, e); len(fields) > 0 {
+			changes = append(changes, Change{Kind: Modified, ID: id, Label: label, Fields: fields})
+		}
+	}
+	for _, e := range from {
+		if id, label := key(e); !seen[id] {
+			changes = append(changes, Change{Kind: Deleted, ID: id, Label: label})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].ID < changes[j].ID })
+	return changes
+}
+
+// Entities unmarshaled from the API keep their original JSON, which is
+// compared if both have it, so that changes to fields the structs do not
+// model are reported too. Otherwise the typed fields are compared.
+func diffFields(x, y any) []FieldDiff {
+	if rx, ry := originalJSON(x), originalJSON(y); rx != nil && ry != nil {
+		var dx, dy map[string]any
+		if json.Unmarshal(rx, &dx) == nil && json.Unmarshal(ry, &dy) == nil {
+			x, y = dx, dy
+		}
+	}
+	r := &fieldReporter{}
+	cmp.Equal(x, y, cmp.Reporter(r), cmp.FilterPath(isDerivedField, cmp.Ignore()))
+	return r.diffs
+}
+
+func originalJSON(e any) json.RawMessage {
+	switch e := e.(type) {
+	case wordpress.Category:
+		return e.JSON
+	case wordpress.Comment:
+		return e.JSON
+	case wordpress.Media:
+		return e.JSON
+	case wordpress.Page:
+		return e.JSON
+	case wordpress.Post:
+		return e.JSON
+	case wordpress.Tag:
+		return e.JSON
+	case wordpress.User:
+		return e.JSON
+	}
+	return nil
+}
+
+// The original JSON and the times parsed from the dates, whose changes are
+// already reported by the other fields.
+func isDerivedField(p cmp.Path) bool {
+	f, ok := p.Last().(cmp.StructField)
+	if !ok {
+		return false
+	}
+	switch f.Name() {
+	case "JSON":
+		return f.Type() == reflect.TypeOf(json.RawMessage(nil))
+	case "DateTime", "ModifiedTime":
+		return f.Type() == reflect.TypeOf(time.Time{})
+	}
+	return false
+}
+
+// Collects the differing leaves of a cmp.Equal comparison.
+type fieldReporter struct {
+	path  cmp.Path
+	diffs []FieldDiff
+}
+
+func (r *fieldReporter) PushStep(ps cmp.PathStep) {
+	r.path = append(r.path, ps)
+}
+
+func (r *fieldReporter) PopStep() {
+	r.path = r.path[:len(r.path)-1]
+}
+
+func (r *fieldReporter) Report(rs cmp.Result) {
+	if rs.Equal() {
+		return
+	}
+	vx, vy := r.path.Last().Values()
+	f := FieldDiff{Path: fieldPath(r.path), Old: value(vx), New: value(vy)}
+	if sx, ok := f.Old.(string); ok {
+		if sy, ok := f.New.(string); ok && (strings.Contains(sx, "\n") || strings.Contains(sy, "\n")) {
+			f.Old, f.New, f.Diff = nil, nil, UnifiedDiff(sx, sy)
+		}
+	}
+	r.diffs = append(r.diffs, f)
+}
+
+func fieldPath(p cmp.Path) string {
+	var sb strings.Builder
+	for _, step := range p {
+		switch s := step.(type) {
+		case cmp.StructField:
+			sb.WriteString("." + s.Name())
+		case cmp.SliceIndex:
+			i, j := s.SplitKeys()
+			if i < 0 {
+				i = j
+			}
+			fmt.Fprintf(&sb, "[%d]", i)
+		case cmp.MapIndex:
+			fmt.Fprintf(&sb, "[%q]", fmt.Sprint(s.Key()))
+		}
+	}
+	return sb.String()
+}
+
+func value(v reflect.Value) any {
+	if !v.IsValid() || !v.CanInterface() {
+		return nil
+	}
+	return v.Interface()
+}
+
+// Decodes the site info, keying the namespaces and plugins so that they are
+// compared by name rather than by position.
+func siteFields(info *wordpress.SiteInfo) (map[string]any, error) {
+	b, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	namespaces := make(map[string]any, len(info.Namespaces))
+	for _, ns := range info.Namespaces {
+		namespaces[ns] = true
+	}
+	fields["namespaces"] = namespaces
+	plugins := make(map[string]any, len(info.Plugins))
+	list, _ := fields["plugins"].([]any)
+	for i, p := range list {
+		plugins[info.Plugins[i].Slug] = p
+	}
+	fields["plugins"] = plugins
+	return fields, nil
+}
+
+func decodeExtra(entities []json.RawMessage) ([]map[string]any, error) {
+	decoded := make([]map[string]any, 0, len(entities))
+	for _, raw := range entities {
+		var e map[string]any
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return nil, err
+		}
+		decoded = append(decoded, e)
+	}
+	return decoded, nil
+}
@@ -0,0 +1,130 @@
+package snapshotdiff
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/ozansz/homelab-functions/pkg/wordpress"
+)
+
+func TestCompare(t *testing.T) {
+	from := &wordpress.SiteContent{
+		Posts: []wordpress.Post{
+			{ID: 1, Slug: "hello", Title: wordpress.RenderedField{Rendered: "Hello"}, DateTime: time.Unix(0, 0)},
+			{ID: 2, Slug: "deleted"},
+			{ID: 4, Slug: "same"},
+		},
+		Pages: []wordpress.Page{
+			{ID: 5, Slug: "about", Content: wordpress.RenderedField{Rendered: "a\nb\nc"}},
+		},
+		Tags: []wordpress.Tag{
+			{ID: 7, Slug: "go", JSON: json.RawMessage(`{"id":7,"slug":"go","meta":{"color":"blue"}}`)},
+		},
+		Users: []wordpress.User{{ID: 9, Slug: "admin"}},
+		Extra: map[string][]json.RawMessage{
+			"/wp/v2/books": {json.RawMessage(`{"id":20,"slug":"dune"}`)},
+		},
+		Site: &wordpress.SiteInfo{
+			Name:       "Old",
+			Namespaces: []string{"wp/v2"},
+			Plugins:    []wordpress.Plugin{{Slug: "akismet", Version: "1.0"}},
+		},
+	}
+	to := &wordpress.SiteContent{
+		Posts: []wordpress.Post{
+			{ID: 3, Slug: "new"},
+			{ID: 1, Slug: "hello", Title: wordpress.RenderedField{Rendered: "Hello, World"}, DateTime: time.Unix(60, 0)},
+			{ID: 4, Slug: "same"},
+		},
+		Pages: []wordpress.Page{
+			{ID: 5, Slug: "about", Content: wordpress.RenderedField{Rendered: "a\nB\nc"}},
+		},
+		Tags: []wordpress.Tag{
+			// The original JSON is compared, including the fields the
+			// struct does not model.
+			{ID: 7, Slug: "go", JSON: json.RawMessage(`{"id":7,"slug":"go","meta":{"color":"green"}}`)},
+		},
+		Extra: map[string][]json.RawMessage{
+			"/wp/v2/movies": {json.RawMessage(`{"id":30,"slug":"alien"}`)},
+		},
+		Truncated: []wordpress.Truncation{
+			{Endpoint: wordpress.EntityEndpoint(wordpress.EntityUsers)},
+			{Endpoint: "/wp/v2/books"},
+		},
+		Site: &wordpress.SiteInfo{
+			Name:       "New",
+			Namespaces: []string{"yoast/v1", "wp/v2"},
+			Plugins:    []wordpress.Plugin{{Slug: "akismet", Version: "1.1"}},
+		},
+	}
+
+	r, err := Compare("a", from, "b", to)
+	if err != nil {
+		t.Fatalf("Compare() failed: %v", err)
+	}
+	wantEntities := map[string][]Change{
+		wordpress.EntityPosts: {
+			{Kind: Modified, ID: 1, Label: "hello", Fields: []FieldDiff{{Path: ".Title.Rendered", Old: "Hello", New: "Hello, World"}}},
+			{Kind: Deleted, ID: 2, Label: "deleted"},
+			{Kind: Created, ID: 3, Label: "new"},
+		},
+		wordpress.EntityPages: {
+			{Kind: Modified, ID: 5, Label: "about", Fields: []FieldDiff{{Path: ".Content.Rendered", Diff: "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n"}}},
+		},
+		wordpress.EntityTags: {
+			{Kind: Modified, ID: 7, Label: "go", Fields: []FieldDiff{{Path: `["meta"]["color"]`, Old: "blue", New: "green"}}},
+		},
+		"/wp/v2/movies": {
+			{Kind: Created, ID: 30, Label: "alien"},
+		},
+	}
+	if diff := cmp.Diff(wantEntities, r.Entities); diff != "" {
+		t.Errorf("Compare() entities mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"/wp/v2/users", "/wp/v2/books"}, r.Truncated); diff != "" {
+		t.Errorf("Compare() truncated mismatch (-want +got):\n%s", diff)
+	}
+	wantSite := []FieldDiff{
+		{Path: `["name"]`, Old: "Old", New: "New"},
+		{Path: `["namespaces"]["yoast/v1"]`, New: true},
+		{Path: `["plugins"]["akismet"]["version"]`, Old: "1.0", New: "1.1"},
+	}
+	if diff := cmp.Diff(wantSite, r.Site); diff != "" {
+		t.Errorf("Compare() site mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCompareWithoutChanges(t *testing.T) {
+	content := &wordpress.SiteContent{
+		Posts: []wordpress.Post{{ID: 1, Slug: "hello"}},
+		Site:  &wordpress.SiteInfo{Name: "Site"},
+	}
+	r, err := Compare("a", content, "b", content)
+	if err != nil {
+		t.Fatalf("Compare() failed: %v", err)
+	}
+	if len(r.Entities) != 0 || len(r.Site) != 0 {
+		t.Errorf("Compare() = %+v, want no changes", r)
+	}
+	var sb strings.Builder
+	if err := r.WriteText(&sb); err != nil {
+		t.Fatalf("WriteText() failed: %v", err)
+	}
+	if want := "changes from a to b\nno changes\n"; sb.String() != want {
+		t.Errorf("WriteText() = %q, want %q", sb.String(), want)
+	}
+}
+
+func TestCompareSiteOnlyWithBothSnapshots(t *testing.T) {
+	r, err := Compare("a", &wordpress.SiteContent{}, "b", &wordpress.SiteContent{Site: &wordpress.SiteInfo{Name: "Site"}})
+	if err != nil {
+		t.Fatalf("Compare() failed: %v", err)
+	}
+	if r.Site != nil {
+		t.Errorf("Compare() site = %+v, want nil if only one snapshot has it", r.Site)
+	}
+}
@@ -0,0 +1,160 @@
+package snapshotdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	contextLines = 3
+	maxLCSCells  = 4 << 20
+)
+
+// Returns a unified diff of the lines of a and b, or an empty string if they
+// are equal.
+func UnifiedDiff(a, b string) string {
+	if a == b {
+		return ""
+	}
+	x, y := strings.Split(a, "\n"), strings.Split(b, "\n")
+	ops := diffLines(x, y)
+
+	var sb strings.Builder
+	for start := 0; start < len(ops); {
+		// Find the next change and the end of its hunk, merging changes
+		// that are closer than twice the context.
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		last := first
+		for i := first; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				last = i
+			} else if i-last > 2*contextLines {
+				break
+			}
+		}
+		from := first - contextLines
+		if from < start {
+			from = start
+		}
+		to := last + contextLines + 1
+		if to > len(ops) {
+			to = len(ops)
+		}
+
+		hunk := ops[from:to]
+		xStart, yStart, xLen, yLen := hunk[0].x+1, hunk[0].y+1, 0, 0
+		for _, op := range hunk {
+			if op.kind != '+' {
+				xLen++
+			}
+			if op.kind != '-' {
+				yLen++
+			}
+		}
+		if xLen == 0 {
+			xStart--
+		}
+		if yLen == 0 {
+			yStart--
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", xStart, xLen, yStart, yLen)
+		for _, op := range hunk {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.line)
+			sb.WriteByte('\n')
+		}
+		start = to
+	}
+	return sb.String()
+}
+
+type lineOp struct {
+	kind byte
+	line string
+	// Indexes of the line in the old and new text, or of the next line for
+	// the side that does not contain it.
+	x, y int
+}
+
+// Computes the longest common subsequence of the lines and returns the edit
+// script turning x into y.
+func diffLines(x, y []string) []lineOp {
+	// The common prefix and suffix are cheap to match and usually make up
+	// most of an edited post.
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+	var ops []lineOp
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, lineOp{kind: ' ', line: x[i], x: i, y: i})
+	}
+	for _, op := range diffMiddle(x[prefix:len(x)-suffix], y[prefix:len(y)-suffix]) {
+		op.x += prefix
+		op.y += prefix
+		ops = append(ops, op)
+	}
+	for i := suffix; i > 0; i-- {
+		ops = append(ops, lineOp{kind: ' ', line: x[len(x)-i], x: len(x) - i, y: len(y) - i})
+	}
+	return ops
+}
+
+func diffMiddle(x, y []string) []lineOp {
+	var ops []lineOp
+	// Give up on finding common lines if the table would be too large, and
+	// replace the whole middle part instead.
+	if len(x)*len(y) > maxLCSCells {
+		for i, l := range x {
+			ops = append(ops, lineOp{kind: '-', line: l, x: i})
+		}
+		for j, l := range y {
+			ops = append(ops, lineOp{kind: '+', line: l, x: len(x), y: j})
+		}
+		return ops
+	}
+
+	// lcs[i][j] is the length of the LCS of x[i:] and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			ops = append(ops, lineOp{kind: ' ', line: x[i], x: i, y: j})
+			i++
+			j++
+		case j < len(y) && (i == len(x) || lcs[i][j+1] > lcs[i+1][j]):
+			ops = append(ops, lineOp{kind: '+', line: y[j], x: i, y: j})
+			j++
+		default:
+			ops = append(ops, lineOp{kind: '-', line: x[i], x: i, y: j})
+			i++
+		}
+	}
+	return ops
+}
@@ -0,0 +1,114 @@
+package snapshotdiff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// Returns the lines "1" to "n".
+func numberedLines(n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprint(i + 1)
+	}
+	return lines
+}
+
+// Returns the numbered lines with the given ones replaced.
+func replaceLines(n int, replaced map[int]string) string {
+	lines := numberedLines(n)
+	for i, l := range replaced {
+		lines[i-1] = l
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "equal",
+			a:    "a\nb",
+			b:    "a\nb",
+			want: "",
+		},
+		{
+			name: "change with context",
+			a:    replaceLines(10, nil),
+			b:    replaceLines(10, map[int]string{5: "five"}),
+			want: "@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "change at the start and end",
+			a:    "a\nb\nc",
+			b:    "A\nb\nC",
+			want: "@@ -1,3 +1,3 @@\n-a\n+A\n b\n-c\n+C\n",
+		},
+		{
+			name: "insertion",
+			a:    "a\nb",
+			b:    "a\nx\nb",
+			want: "@@ -1,2 +1,3 @@\n a\n+x\n b\n",
+		},
+		{
+			name: "deletion",
+			a:    "a\nx\nb",
+			b:    "a\nb",
+			want: "@@ -1,3 +1,2 @@\n a\n-x\n b\n",
+		},
+		{
+			name: "changes six lines apart are merged",
+			a:    replaceLines(20, nil),
+			b:    replaceLines(20, map[int]string{5: "five", 12: "twelve"}),
+			want: "@@ -2,14 +2,14 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n 9\n 10\n 11\n-12\n+twelve\n 13\n 14\n 15\n",
+		},
+		{
+			name: "changes seven lines apart are split",
+			a:    replaceLines(20, nil),
+			b:    replaceLines(20, map[int]string{5: "five", 13: "thirteen"}),
+			want: "@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n" +
+				"@@ -10,7 +10,7 @@\n 10\n 11\n 12\n-13\n+thirteen\n 14\n 15\n 16\n",
+		},
+		{
+			name: "hunks with line offsets",
+			a:    replaceLines(20, nil),
+			b:    strings.Replace(strings.Replace(replaceLines(20, nil), "\n3\n", "\n3\nx\ny\n", 1), "\n16\n", "\n", 1),
+			want: "@@ -1,6 +1,8 @@\n 1\n 2\n 3\n+x\n+y\n 4\n 5\n 6\n" +
+				"@@ -13,7 +15,6 @@\n 13\n 14\n 15\n-16\n 17\n 18\n 19\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, UnifiedDiff(tc.a, tc.b)); diff != "" {
+				t.Errorf("UnifiedDiff() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDiffLinesWithoutLCS(t *testing.T) {
+	// The middle part is replaced as a whole if the LCS table would be too
+	// large, the common prefix and suffix are still matched.
+	n := 2100
+	a := append(append([]string{"head"}, numberedLines(n)...), "tail")
+	b := append(append([]string{"head"}, numberedLines(n)...), "tail")
+	for i := 1; i <= n; i++ {
+		b[i] = "new " + b[i]
+	}
+	ops := diffLines(a, b)
+	counts := make(map[byte]int)
+	for _, op := range ops {
+		counts[op.kind]++
+	}
+	if diff := cmp.Diff(map[byte]int{' ': 2, '-': n, '+': n}, counts); diff != "" {
+		t.Errorf("diffLines() op counts mismatch (-want +got):\n%s", diff)
+	}
+	if first, last := ops[0], ops[len(ops)-1]; first.line != "head" || last.line != "tail" || last.x != n+1 || last.y != n+1 {
+		t.Errorf("diffLines() = first %+v, last %+v, want the common head and tail", first, last)
+	}
+}
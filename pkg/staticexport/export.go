@@ -0,0 +1,351 @@
+// Package staticexport converts WordPress content into the Markdown source
+// tree of a static site generator.
+package staticexport
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-yaml/yaml"
+
+	"github.com/ozansz/homelab-functions/pkg/wordpress"
+)
+
+type Flavor string
+
+const (
+	Hugo   Flavor = "hugo"
+	Jekyll Flavor = "jekyll"
+)
+
+const (
+	wpDateLayout  = "2006-01-02T15:04:05"
+	wpUploadsPath = "/wp-content/uploads/"
+	uploadsURL    = "/uploads/"
+)
+
+type Options struct {
+	Flavor Flavor
+	// URL of the crawled site. Links to its posts, pages and uploads are
+	// rewritten to the new layout.
+	SiteURL string
+}
+
+type Site struct {
+	// Markdown files keyed by their path in the source tree.
+	Files map[string][]byte
+	// Source URLs of the media files keyed by their path in the source
+	// tree. The files are not downloaded by the export.
+	Media map[string]string
+}
+
+// Posts go to content/posts/<slug>.md for Hugo and
+// _posts/<date>-<slug>.md for Jekyll, pages keep their parent hierarchy and
+// uploads are served from /uploads/. The front matter lists the old
+// permalink as an alias, so that redirects can be generated.
+func Export(content *wordpress.SiteContent, opts Options) (*Site, error) {
+	switch opts.Flavor {
+	case Hugo, Jekyll:
+	default:
+		return nil, fmt.Errorf("unknown flavor %q", opts.Flavor)
+	}
+	siteURL, err := url.Parse(opts.SiteURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse site URL: %w", err)
+	}
+
+	e := &exporter{
+		opts:       opts,
+		host:       siteURL.Host,
+		categories: make(map[int]string, len(content.Categories)),
+		tags:       make(map[int]string, len(content.Tags)),
+		users:      make(map[int]string, len(content.Users)),
+		links:      make(map[string]string),
+		ids:        make(map[int]string),
+		site: &Site{
+			Files: make(map[string][]byte),
+			Media: make(map[string]string),
+		},
+	}
+	for _, c := range content.Categories {
+		e.categories[c.ID] = html.UnescapeString(c.Name)
+	}
+	for _, t := range content.Tags {
+		e.tags[t.ID] = html.UnescapeString(t.Name)
+	}
+	for _, u := range content.Users {
+		e.users[u.ID] = html.UnescapeString(u.Name)
+	}
+	for _, m := range content.Media {
+		for _, fileURL := range m.FileURLs() {
+			e.site.Media[e.mediaDir()+mediaPath(fileURL, m.ID)] = fileURL
+		}
+	}
+
+	// Map the old permalinks first, so that links between posts and pages
+	// can be rewritten regardless of their order.
+	pages := make(map[int]wordpress.Page, len(content.Pages))
+	for _, p := range content.Pages {
+		pages[p.ID] = p
+	}
+	var docs []document
+	for _, p := range content.Posts {
+		docs = append(docs, e.postDocument(p))
+	}
+	for _, p := range content.Pages {
+		docs = append(docs, e.pageDocument(p, pages))
+	}
+	for _, d := range docs {
+		if old := linkPath(d.oldLink); old != "" {
+			e.links[old] = d.url
+		}
+		e.ids[d.id] = d.url
+	}
+
+	for _, d := range docs {
+		if err := e.write(d); err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", d.oldLink, err)
+		}
+	}
+	return e.site, nil
+}
+
+type exporter struct {
+	opts       Options
+	host       string
+	categories map[int]string
+	tags       map[int]string
+	users      map[int]string
+	// New URLs keyed by the path of the old permalink and by ID.
+	links map[string]string
+	ids   map[int]string
+	site  *Site
+}
+
+// A post or page to be written.
+type document struct {
+	id         int
+	file       string
+	url        string
+	oldLink    string
+	kind       string
+	title      string
+	date       time.Time
+	modified   time.Time
+	slug       string
+	status     string
+	author     int
+	categories []int
+	tags       []int
+	html       string
+}
+
+func (e *exporter) postDocument(p wordpress.Post) document {
+	d := document{
+		id:         p.ID,
+		oldLink:    p.Link,
+		kind:       "post",
+		title:      p.PlainTitle(),
+		date:       p.DateTime,
+		modified:   p.ModifiedTime,
+		slug:       slug(p.Slug, p.ID),
+		status:     p.Status,
+		author:     p.Author,
+		categories: p.Categories,
+		tags:       p.Tags,
+		html:       p.Content.Rendered,
+	}
+	if e.opts.Flavor == Hugo {
+		d.file = "content/posts/" + d.slug + ".md"
+		d.url = "/posts/" + d.slug + "/"
+	} else {
+		date := "0001-01-01"
+		if t, err := time.Parse(wpDateLayout, p.Date); err == nil {
+			date = t.Format("2006-01-02")
+		}
+		d.file = "_posts/" + date + "-" + d.slug + ".md"
+		d.url = "/" + strings.ReplaceAll(date, "-", "/") + "/" + d.slug + "/"
+	}
+	return d
+}
+
+func (e *exporter) pageDocument(p wordpress.Page, pages map[int]wordpress.Page) document {
+	d := document{
+		id:       p.ID,
+		oldLink:  p.Link,
+		kind:     "page",
+		title:    p.PlainTitle(),
+		date:     p.DateTime,
+		modified: p.ModifiedTime,
+		slug:     slug(p.Slug, p.ID),
+		status:   p.Status,
+		author:   p.Author,
+		html:     p.Content.Rendered,
+	}
+	// Guard against parent cycles in broken data.
+	segments := []string{d.slug}
+	seen := map[int]bool{p.ID: true}
+	for parent, ok := pages[p.Parent]; ok && !seen[parent.ID]; parent, ok = pages[parent.Parent] {
+		seen[parent.ID] = true
+		segments = append([]string{slug(parent.Slug, parent.ID)}, segments...)
+	}
+	dir := strings.Join(segments, "/")
+	if e.opts.Flavor == Hugo {
+		d.file = "content/" + dir + ".md"
+	} else {
+		d.file = dir + ".md"
+	}
+	d.url = "/" + dir + "/"
+	return d
+}
+
+func (e *exporter) write(d document) error {
+	body, err := HTMLToMarkdown(d.html, e.rewrite)
+	if err != nil {
+		return err
+	}
+
+	fm := yaml.MapSlice{
+		{Key: "title", Value: d.title},
+	}
+	if e.opts.Flavor == Jekyll {
+		fm = append(yaml.MapSlice{{Key: "layout", Value: d.kind}}, fm...)
+	}
+	if date := frontMatterDate(d.date); date != "" {
+		fm = append(fm, yaml.MapItem{Key: "date", Value: date})
+	}
+	if modified := frontMatterDate(d.modified); modified != "" {
+		key := "lastmod"
+		if e.opts.Flavor == Jekyll {
+			key = "last_modified_at"
+		}
+		fm = append(fm, yaml.MapItem{Key: key, Value: modified})
+	}
+	fm = append(fm, yaml.MapItem{Key: "slug", Value: d.slug})
+	if author := e.users[d.author]; author != "" {
+		fm = append(fm, yaml.MapItem{Key: "author", Value: author})
+	}
+	if names := resolve(d.categories, e.categories); len(names) > 0 {
+		fm = append(fm, yaml.MapItem{Key: "categories", Value: names})
+	}
+	if names := resolve(d.tags, e.tags); len(names) > 0 {
+		fm = append(fm, yaml.MapItem{Key: "tags", Value: names})
+	}
+	if e.opts.Flavor == Jekyll {
+		fm = append(fm, yaml.MapItem{Key: "permalink", Value: d.url})
+	}
+	if old := linkPath(d.oldLink); old != "" && "/"+old+"/" != d.url {
+		key := "aliases"
+		if e.opts.Flavor == Jekyll {
+			// Used by the jekyll-redirect-from plugin.
+			key = "redirect_from"
+		}
+		fm = append(fm, yaml.MapItem{Key: key, Value: []string{"/" + old + "/"}})
+	}
+	if d.status != "" && d.status != "publish" {
+		if e.opts.Flavor == Jekyll {
+			fm = append(fm, yaml.MapItem{Key: "published", Value: false})
+		} else {
+			fm = append(fm, yaml.MapItem{Key: "draft", Value: true})
+		}
+	}
+
+	b, err := yaml.Marshal(fm)
+	if err != nil {
+		return fmt.Errorf("failed to marshal front matter: %w", err)
+	}
+	doc := "---\n" + string(b) + "---\n"
+	if body != "" {
+		doc += "\n" + body + "\n"
+	}
+	e.site.Files[d.file] = []byte(doc)
+	return nil
+}
+
+// Rewrites links to the crawled site's posts, pages and uploads to their
+// new URLs. Other links are returned as is.
+func (e *exporter) rewrite(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Host != "" && u.Host != e.host) || (u.Host == "" && !strings.HasPrefix(u.Path, "/")) {
+		return raw
+	}
+	if i := strings.Index(u.Path, wpUploadsPath); i >= 0 {
+		return uploadsURL + u.Path[i+len(wpUploadsPath):]
+	}
+	to, ok := e.links[linkPath(raw)]
+	if !ok {
+		// Plain permalinks and shortlinks, e.g. /?p=123.
+		for _, key := range []string{"p", "page_id"} {
+			if id, err := strconv.Atoi(u.Query().Get(key)); err == nil {
+				to, ok = e.ids[id]
+				break
+			}
+		}
+	}
+	if !ok {
+		return raw
+	}
+	if u.Fragment != "" {
+		to += "#" + u.Fragment
+	}
+	return to
+}
+
+func (e *exporter) mediaDir() string {
+	if e.opts.Flavor == Hugo {
+		return "static" + uploadsURL
+	}
+	return strings.TrimPrefix(uploadsURL, "/")
+}
+
+// Returns the path of the uploaded file relative to the uploads directory,
+// keeping the year/month folders of WordPress.
+func mediaPath(fileURL string, id int) string {
+	u, err := url.Parse(fileURL)
+	if err == nil {
+		if i := strings.Index(u.Path, wpUploadsPath); i >= 0 {
+			return u.Path[i+len(wpUploadsPath):]
+		}
+		return fmt.Sprintf("%d/%s", id, path.Base(u.Path))
+	}
+	return fmt.Sprintf("%d/%s", id, path.Base(fileURL))
+}
+
+// Returns the path of the link without the surrounding slashes, or an empty
+// string for links with a query, which are not pretty permalinks.
+func linkPath(link string) string {
+	u, err := url.Parse(link)
+	if err != nil || u.RawQuery != "" {
+		return ""
+	}
+	return strings.Trim(u.Path, "/")
+}
+
+func slug(s string, id int) string {
+	if s == "" {
+		return strconv.Itoa(id)
+	}
+	return s
+}
+
+func resolve(ids []int, names map[int]string) []string {
+	var resolved []string
+	for _, id := range ids {
+		if name, ok := names[id]; ok {
+			resolved = append(resolved, name)
+		}
+	}
+	return resolved
+}
+
+func frontMatterDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,240 @@
+package staticexport
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	whitespaceRegexp = regexp.MustCompile(`\s+`)
+	markdownEscaper  = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`)
+	// Characters that cannot be in a link destination unless it is enclosed
+	// in angle brackets, which themselves must be encoded.
+	destinationEscaper = strings.NewReplacer("<", "%3C", ">", "%3E", "\n", "%0A")
+)
+
+// Converts rendered post HTML into Markdown. The URLs of links and images
+// are passed through rewrite. Elements without a Markdown equivalent, like
+// tables and embeds, are kept as HTML.
+func HTMLToMarkdown(s string, rewrite func(string) string) (string, error) {
+	if rewrite == nil {
+		rewrite = func(u string) string { return u }
+	}
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(s), body)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse HTML: %w", err)
+	}
+	c := &converter{rewrite: rewrite}
+	var sb strings.Builder
+	for _, n := range nodes {
+		sb.WriteString(c.convert(n))
+	}
+	return tidy(sb.String()), nil
+}
+
+type converter struct {
+	rewrite func(string) string
+}
+
+func (c *converter) convert(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		text := whitespaceRegexp.ReplaceAllString(n.Data, " ")
+		if n.PrevSibling != nil && n.PrevSibling.DataAtom == atom.Br {
+			text = strings.TrimLeft(text, " ")
+		}
+		return markdownEscaper.Replace(text)
+	case html.ElementNode:
+	default:
+		return ""
+	}
+
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Noscript:
+		return ""
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Header, atom.Footer, atom.Figure:
+		return block(strings.TrimSpace(c.children(n)))
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level := int(n.Data[1] - '0')
+		return block(strings.Repeat("#", level) + " " + strings.TrimSpace(c.children(n)))
+	case atom.Figcaption:
+		return block(emphasize("*", c.children(n)))
+	case atom.Br:
+		return "\\\n"
+	case atom.Hr:
+		return block("---")
+	case atom.Strong, atom.B:
+		return emphasize("**", c.children(n))
+	case atom.Em, atom.I:
+		return emphasize("*", c.children(n))
+	case atom.Del, atom.S:
+		return emphasize("~~", c.children(n))
+	case atom.Code:
+		return "`" + textContent(n) + "`"
+	case atom.Pre:
+		lang := ""
+		code := n
+		if n.FirstChild != nil && n.FirstChild.DataAtom == atom.Code && n.FirstChild.NextSibling == nil {
+			code = n.FirstChild
+		}
+		for _, class := range strings.Fields(attr(code, "class")) {
+			if strings.HasPrefix(class, "language-") {
+				lang = strings.TrimPrefix(class, "language-")
+			}
+		}
+		return block("```" + lang + "\n" + strings.TrimRight(textContent(code), "\n") + "\n```")
+	case atom.A:
+		text := strings.TrimSpace(c.children(n))
+		href := attr(n, "href")
+		if href == "" || text == "" {
+			return text
+		}
+		return "[" + text + "](" + destination(c.rewrite(href)) + ")"
+	case atom.Img:
+		src := attr(n, "src")
+		if src == "" {
+			return ""
+		}
+		return "![" + markdownEscaper.Replace(attr(n, "alt")) + "](" + destination(c.rewrite(src)) + ")"
+	case atom.Ul, atom.Ol:
+		return block(c.list(n))
+	case atom.Blockquote:
+		lines := strings.Split(tidy(c.children(n)), "\n")
+		for i, l := range lines {
+			lines[i] = strings.TrimRight("> "+l, " ")
+		}
+		return block(strings.Join(lines, "\n"))
+	case atom.Table, atom.Iframe, atom.Video, atom.Audio, atom.Object, atom.Embed:
+		return block(c.rawHTML(n))
+	}
+	return c.children(n)
+}
+
+func (c *converter) children(n *html.Node) string {
+	var sb strings.Builder
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		sb.WriteString(c.convert(ch))
+	}
+	return sb.String()
+}
+
+func (c *converter) list(n *html.Node) string {
+	var items []string
+	i := 1
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.DataAtom != atom.Li {
+			continue
+		}
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = fmt.Sprintf("%d. ", i)
+		}
+		i++
+		// Nested blocks are indented to the item's content.
+		indent := strings.Repeat(" ", len(marker))
+		lines := strings.Split(tidy(c.children(li)), "\n")
+		for j := 1; j < len(lines); j++ {
+			if lines[j] != "" {
+				lines[j] = indent + lines[j]
+			}
+		}
+		items = append(items, marker+strings.Join(lines, "\n"))
+	}
+	return strings.Join(items, "\n")
+}
+
+// Renders the element as HTML, rewriting the URLs in it.
+func (c *converter) rawHTML(n *html.Node) string {
+	var rewrite func(*html.Node)
+	rewrite = func(n *html.Node) {
+		for i, a := range n.Attr {
+			if a.Key == "href" || a.Key == "src" {
+				n.Attr[i].Val = c.rewrite(a.Val)
+			}
+		}
+		for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+			rewrite(ch)
+		}
+	}
+	rewrite(n)
+	var buf bytes.Buffer
+	if err := html.Render(&buf, n); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// Returns the URL as a link destination, enclosed in angle brackets if it has
+// spaces or parentheses.
+func destination(u string) string {
+	if !strings.ContainsAny(u, " ()<>\n") {
+		return u
+	}
+	return "<" + destinationEscaper.Replace(u) + ">"
+}
+
+func block(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "\n\n" + s + "\n\n"
+}
+
+func emphasize(marker, s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ""
+	}
+	return marker + s + marker
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		sb.WriteString(textContent(ch))
+	}
+	return sb.String()
+}
+
+// Removes trailing whitespace from the lines outside code blocks and
+// collapses runs of blank lines outside them.
+func tidy(s string) string {
+	lines := strings.Split(s, "\n")
+	tidied := lines[:0]
+	inCode, blank := false, false
+	for _, l := range lines {
+		fence := strings.HasPrefix(strings.TrimSpace(l), "```")
+		if fence {
+			inCode = !inCode
+		}
+		if inCode && !fence {
+			tidied = append(tidied, l)
+			continue
+		}
+		l = strings.TrimRight(l, " \t")
+		if l == "" && blank {
+			continue
+		}
+		blank = l == ""
+		tidied = append(tidied, l)
+	}
+	return strings.TrimSpace(strings.Join(tidied, "\n"))
+}
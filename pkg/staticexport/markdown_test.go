@@ -0,0 +1,145 @@
+package staticexport
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTMLToMarkdown(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "paragraphs and emphasis",
+			html: "<p>Hello <strong>bold</strong> and <em>italic</em></p>\n\n\n<p>Second</p>",
+			want: "Hello **bold** and *italic*\n\nSecond",
+		},
+		{
+			name: "headings",
+			html: "<h2>Title</h2><p>Text</p>",
+			want: "## Title\n\nText",
+		},
+		{
+			name: "escapes markdown characters",
+			html: "<p>*not* _emphasis_ [link] `code` a\\b</p>",
+			want: "\\*not\\* \\_emphasis\\_ \\[link\\] \\`code\\` a\\\\b",
+		},
+		{
+			name: "escapes angle brackets",
+			html: "<p>&lt;script&gt;alert(1)&lt;/script&gt; and a &gt; b</p>",
+			want: "\\<script\\>alert(1)\\</script\\> and a \\> b",
+		},
+		{
+			name: "line breaks",
+			html: "<p>one<br>two<br/> three</p>",
+			want: "one\\\ntwo\\\nthree",
+		},
+		{
+			name: "links",
+			html: `<p><a href="https://example.com/a">A</a> <a href="">empty</a> <a href="https://example.com/b"></a></p>`,
+			want: "[A](https://example.com/a) empty",
+		},
+		{
+			name: "link destinations with spaces and parentheses",
+			html: `<p><a href="https://example.com/my file.pdf">file</a> <a href="https://en.wikipedia.org/wiki/Go_(language)">Go</a></p>`,
+			want: "[file](<https://example.com/my file.pdf>) [Go](<https://en.wikipedia.org/wiki/Go_(language)>)",
+		},
+		{
+			name: "images",
+			html: `<figure><img src="https://example.com/a b.png" alt="An *image*"><figcaption>Caption</figcaption></figure>`,
+			want: "![An \\*image\\*](<https://example.com/a b.png>)\n\n*Caption*",
+		},
+		{
+			name: "code block keeps blank lines and whitespace",
+			html: "<pre><code class=\"language-go\">func main() {\n\n\n\tfmt.Println(\"*hi*\")  \n}\n</code></pre>",
+			want: "```go\nfunc main() {\n\n\n\tfmt.Println(\"*hi*\")  \n}\n```",
+		},
+		{
+			name: "inline code is not escaped",
+			html: "<p>Run <code>go test ./...</code> and <code>a_b</code></p>",
+			want: "Run `go test ./...` and `a_b`",
+		},
+		{
+			name: "lists",
+			html: "<ul><li>one</li><li>two<ol><li>nested</li></ol></li></ul>",
+			want: "- one\n- two\n\n  1. nested",
+		},
+		{
+			name: "blockquote",
+			html: "<blockquote><p>first</p><p>second</p></blockquote>",
+			want: "> first\n>\n> second",
+		},
+		{
+			name: "tables are kept as HTML",
+			html: `<table><tr><td><a href="/x">x</a></td></tr></table>`,
+			want: `<table><tbody><tr><td><a href="/rewritten/x">x</a></td></tr></tbody></table>`,
+		},
+		{
+			name: "scripts are dropped",
+			html: "<p>text</p><script>alert(1)</script>",
+			want: "text",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := HTMLToMarkdown(tc.html, func(u string) string {
+				if strings.HasPrefix(u, "/") {
+					return "/rewritten" + u
+				}
+				return u
+			})
+			if err != nil {
+				t.Fatalf("HTMLToMarkdown() error = %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("HTMLToMarkdown() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTidy(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		s    string
+		want string
+	}{
+		{
+			name: "collapses blank lines",
+			s:    "\n\na  \n\n\n\nb\t\n\n",
+			want: "a\n\nb",
+		},
+		{
+			name: "keeps code blocks",
+			s:    "a\n\n\n```\nx  \n\n\n\ny\n```\n\n\nb",
+			want: "a\n\n```\nx  \n\n\n\ny\n```\n\nb",
+		},
+		{
+			name: "indented code blocks",
+			s:    "- item\n\n  ```\n  x\n\n\n  y\n  ```\n\n\n",
+			want: "- item\n\n  ```\n  x\n\n\n  y\n  ```",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tidy(tc.s); got != tc.want {
+				t.Errorf("tidy() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDestination(t *testing.T) {
+	for _, tc := range []struct {
+		u, want string
+	}{
+		{"https://example.com/a", "https://example.com/a"},
+		{"/media/a b.png", "</media/a b.png>"},
+		{"https://example.com/x_(y)", "<https://example.com/x_(y)>"},
+		{"https://example.com/<x>", "<https://example.com/%3Cx%3E>"},
+	} {
+		if got := destination(tc.u); got != tc.want {
+			t.Errorf("destination(%q) = %q, want %q", tc.u, got, tc.want)
+		}
+	}
+}
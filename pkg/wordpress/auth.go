@@ -0,0 +1,159 @@
+package wordpress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	contextEdit = "edit"
+
+	// Statuses that include everything an authenticated crawl can see, e.g.
+	// drafts, pending and private posts, but not the trash.
+	postStatusAny    = "any"
+	commentStatusAll = "all"
+)
+
+// Authenticates with a WordPress application password using Basic auth.
+func WithApplicationPassword(username, password string) NewClientOpt {
+	return func(c *Client) {
+		c.auth = func(req *http.Request) {
+			req.SetBasicAuth(username, password)
+		}
+	}
+}
+
+// Authenticates with a bearer token, e.g. one issued by a JWT auth plugin.
+func WithBearerToken(token string) NewClientOpt {
+	return func(c *Client) {
+		c.auth = func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+}
+
+// Authenticates with a logged in user's cookie and a wp_rest nonce.
+func WithCookieNonce(cookie, nonce string) NewClientOpt {
+	return func(c *Client) {
+		c.auth = func(req *http.Request) {
+			req.Header.Set("Cookie", cookie)
+			req.Header.Set("X-WP-Nonce", nonce)
+		}
+	}
+}
+
+// Requests context=edit, which makes the API return raw (unrendered) fields
+// and private data like user emails, and includes entities with statuses
+// other than publish. Requires an authenticated client.
+func WithEditContext() NewClientOpt {
+	return func(c *Client) {
+		c.context = contextEdit
+	}
+}
+
+// Credentials to authenticate with, e.g. read from the environment. At most
+// one authentication method is used, preferring an application password over
+// a bearer token over a cookie.
+type Credentials struct {
+	Username    string
+	AppPassword string
+	BearerToken string
+	Cookie      string
+	Nonce       string
+}
+
+// Where a secret is read from: the file if given, otherwise the environment
+// variable. Either may be empty.
+type Secret struct {
+	Env  string
+	File string
+}
+
+func (s Secret) Read() (string, error) {
+	if s.File == "" {
+		if s.Env == "" {
+			return "", nil
+		}
+		return os.Getenv(s.Env), nil
+	}
+	b, err := os.ReadFile(s.File)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret: %w", err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// Where the secrets of Credentials are read from.
+type CredentialSources struct {
+	Username    string
+	AppPassword Secret
+	BearerToken Secret
+	Cookie      Secret
+	Nonce       Secret
+}
+
+func (s CredentialSources) Read() (Credentials, error) {
+	var (
+		creds = Credentials{Username: s.Username}
+		err   error
+	)
+	if creds.AppPassword, err = s.AppPassword.Read(); err != nil {
+		return creds, err
+	}
+	if creds.BearerToken, err = s.BearerToken.Read(); err != nil {
+		return creds, err
+	}
+	if creds.Cookie, err = s.Cookie.Read(); err != nil {
+		return creds, err
+	}
+	if creds.Nonce, err = s.Nonce.Read(); err != nil {
+		return creds, err
+	}
+	return creds, nil
+}
+
+// Returns the options to authenticate with the credentials, nil if there are
+// none. Authenticated clients use the edit context.
+func (cr Credentials) Options() ([]NewClientOpt, error) {
+	switch {
+	case cr.Username != "" && cr.AppPassword != "":
+		log.Printf("authenticating as %s with an application password", cr.Username)
+		return []NewClientOpt{WithApplicationPassword(cr.Username, cr.AppPassword), WithEditContext()}, nil
+	case cr.Username != "":
+		return nil, fmt.Errorf("username is set but no application password is given")
+	case cr.BearerToken != "":
+		log.Printf("authenticating with a bearer token")
+		return []NewClientOpt{WithBearerToken(cr.BearerToken), WithEditContext()}, nil
+	case cr.Cookie != "" && cr.Nonce != "":
+		log.Printf("authenticating with a cookie and nonce")
+		return []NewClientOpt{WithCookieNonce(cr.Cookie, cr.Nonce), WithEditContext()}, nil
+	case cr.Cookie != "" || cr.Nonce != "":
+		return nil, fmt.Errorf("cookie authentication requires both a cookie and a nonce")
+	}
+	return nil, nil
+}
+
+// Creates a request carrying the client's credentials. Credentials are only
+// sent to the site itself, never to e.g. a CDN serving its media files.
+func (c *Client) newRequest(ctx context.Context, method, rawURL string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.auth != nil {
+		if base, err := url.Parse(c.baseURL); err == nil && base.Host == req.URL.Host {
+			c.auth(req)
+		}
+	}
+	return req, nil
+}
+
+func (c *Client) IsAuthenticated() bool {
+	return c.auth != nil
+}
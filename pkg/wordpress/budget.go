@@ -0,0 +1,110 @@
+package wordpress
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+const (
+	TruncatedMaxPages    = "max_pages"
+	TruncatedMaxEntities = "max_entities"
+	TruncatedDeadline    = "deadline"
+	// Some entities could not be fetched, only set when scraping.
+	TruncatedFetchFailed = "fetch_failed"
+)
+
+var (
+	errMaxPages    = errors.New("page budget exhausted")
+	errMaxEntities = errors.New("entity budget exhausted")
+)
+
+// Records an entity list that was cut short by a crawl budget, or that misses
+// entities that could not be scraped, so that the content is not mistaken for
+// a complete backup.
+type Truncation struct {
+	// Collection endpoint, e.g. "/wp/v2/posts", or EntityCollections if the
+	// custom collections could not be discovered.
+	Endpoint string `json:"endpoint"`
+	// One of the Truncated* constants.
+	Reason string `json:"reason"`
+	// Number of entities fetched before the budget ran out, or that could be
+	// fetched.
+	Fetched int `json:"fetched"`
+}
+
+// Limits the number of pages fetched per entity list. The entities of the
+// remaining pages are left out.
+func WithMaxPages(n int) NewClientOpt {
+	return func(c *Client) {
+		c.maxPages = n
+	}
+}
+
+// Limits the number of entities fetched per entity list.
+func WithMaxEntities(n int) NewClientOpt {
+	return func(c *Client) {
+		c.maxEntities = n
+	}
+}
+
+// Cancels the requests still in flight at the deadline and stops fetching.
+// The entities fetched until then are kept.
+func WithDeadline(t time.Time) NewClientOpt {
+	return func(c *Client) {
+		c.deadline = t
+	}
+}
+
+// Returns the entity lists truncated by the budgets so far.
+func (c *Client) Truncations() []Truncation {
+	c.truncationsMu.Lock()
+	defer c.truncationsMu.Unlock()
+	return append([]Truncation(nil), c.truncations...)
+}
+
+func (c *Client) truncate(endpoint, reason string, fetched int) {
+	log.Printf("%s truncated after %d entities: %s", endpoint, fetched, reason)
+	c.truncationsMu.Lock()
+	defer c.truncationsMu.Unlock()
+	c.truncations = append(c.truncations, Truncation{Endpoint: endpoint, Reason: reason, Fetched: fetched})
+}
+
+func (c *Client) truncated(endpoint string) bool {
+	c.truncationsMu.Lock()
+	defer c.truncationsMu.Unlock()
+	for _, t := range c.truncations {
+		if t.Endpoint == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the reason the entity list of the endpoint ended early, or an empty
+// string if the error is not caused by a budget. Errors caused by the
+// caller's context are not budgets either.
+func (c *Client) budgetReason(ctx context.Context, err error) string {
+	switch {
+	case errors.Is(err, errMaxPages):
+		return TruncatedMaxPages
+	case errors.Is(err, errMaxEntities):
+		return TruncatedMaxEntities
+	case c.pastDeadline() && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded):
+		return TruncatedDeadline
+	}
+	return ""
+}
+
+func (c *Client) pastDeadline() bool {
+	return !c.deadline.IsZero() && !time.Now().Before(c.deadline)
+}
+
+// Applies the deadline to a request.
+func (c *Client) withDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.deadline.IsZero() {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, c.deadline)
+}
@@ -0,0 +1,167 @@
+package wordpress
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// Returned in offline mode for requests without a cached response.
+var ErrNotCached = errors.New("response is not cached")
+
+// Stores the successful responses of the REST API and of the fetched site
+// pages, e.g. sitemaps, in dir. Later requests are sent with If-None-Match
+// and If-Modified-Since if the cached response has an ETag or Last-Modified
+// header, and the cached body is reused if the site responds with 304 Not
+// Modified. Media downloads are not cached.
+func WithCache(dir string) NewClientOpt {
+	return func(c *Client) {
+		c.cache = &httpCache{dir: dir}
+	}
+}
+
+// Serves requests only from the cache set with WithCache, without sending
+// them to the site. Requests missing from the cache and media downloads fail
+// with ErrNotCached.
+func WithOffline() NewClientOpt {
+	return func(c *Client) {
+		c.offline = true
+	}
+}
+
+type httpCache struct {
+	dir string
+}
+
+type cachedResponse struct {
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// Sends a GET request through the cache.
+func (c *Client) cachedDo(cl *http.Client, req *http.Request) (*http.Response, error) {
+	if c.cache == nil {
+		if c.offline {
+			return nil, fmt.Errorf("offline mode requires a cache")
+		}
+		return c.do(cl, req)
+	}
+
+	name := c.cache.fileName(req)
+	cached, err := c.cache.load(name)
+	if err != nil {
+		log.Printf("failed to read cached response of %s: %v", req.URL, err)
+	}
+	if c.offline {
+		if cached == nil {
+			return nil, fmt.Errorf("%w: %s", ErrNotCached, req.URL)
+		}
+		return cached.response(req), nil
+	}
+	if cached != nil {
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := cached.Header.Get("Last-Modified"); lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	res, err := c.do(cl, req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case res.StatusCode == http.StatusNotModified && cached != nil:
+		res.Body.Close()
+		log.Printf("%s is not modified, using the cached response", req.URL)
+		return cached.response(req), nil
+	case res.StatusCode == http.StatusOK:
+		b, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response body: %w", err)
+		}
+		if err := c.cache.store(name, &cachedResponse{URL: req.URL.String(), Header: res.Header, Body: b}); err != nil {
+			log.Printf("failed to cache response of %s: %v", req.URL, err)
+		}
+		res.Body = io.NopCloser(bytes.NewReader(b))
+	}
+	return res, nil
+}
+
+// Responses are keyed by the URL and the credentials, which change e.g. the
+// visible entities and fields. The credentials are hashed with the URL and
+// never stored.
+func (h *httpCache) fileName(req *http.Request) string {
+	sum := sha256.New()
+	for _, s := range []string{req.URL.String(), req.Header.Get("Authorization"), req.Header.Get("Cookie"), req.Header.Get("X-WP-Nonce")} {
+		sum.Write([]byte(s))
+		sum.Write([]byte{0})
+	}
+	return filepath.Join(h.dir, hex.EncodeToString(sum.Sum(nil))+".json")
+}
+
+// Returns nil if the response is not cached.
+func (h *httpCache) load(name string) (*cachedResponse, error) {
+	b, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var r cachedResponse
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Writes the response to a temporary file first, so that concurrent
+// requests never read a partially written response.
+func (h *httpCache) store(name string, r *cachedResponse) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(h.dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(h.dir, "tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+func (r *cachedResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        r.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,57 @@
+package wordpress
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCacheNotModified(t *testing.T) {
+	var notModified int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("X-WP-TotalPages", "1")
+		w.Write([]byte(`[{"id":1},{"id":2}]`))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	list := func(c *Client) ([]testEntity, error) {
+		return List[testEntity](context.Background(), c, EntityEndpoint(EntityPosts), &ListOptions{})
+	}
+	want := testEntities(2)
+	for i := 0; i < 2; i++ {
+		got, err := list(NewClient(srv.URL, WithCache(dir)))
+		if err != nil {
+			t.Fatalf("List() failed: %v", err)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("List() mismatch (-want +got):\n%s", diff)
+		}
+	}
+	if n := atomic.LoadInt32(&notModified); n != 1 {
+		t.Errorf("got %d not modified responses, want 1", n)
+	}
+
+	srv.Close()
+	got, err := list(NewClient(srv.URL, WithCache(dir), WithOffline()))
+	if err != nil {
+		t.Fatalf("List() offline failed: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("List() offline mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := list(NewClient(srv.URL, WithCache(t.TempDir()), WithOffline())); !errors.Is(err, ErrNotCached) {
+		t.Errorf("List() offline without a cached response got error %v, want %v", err, ErrNotCached)
+	}
+}
@@ -7,24 +7,56 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
 	"time"
 )
 
 const (
-	baseAPIPath    = "/wp-json/wp/v2"
+	restPrefix = "/wp-json"
+
+	baseAPIPath    = "/wp/v2"
 	categoriesPath = baseAPIPath + "/categories"
 	commentsPath   = baseAPIPath + "/comments"
+	mediaPath      = baseAPIPath + "/media"
 	pagesPath      = baseAPIPath + "/pages"
 	postsPath      = baseAPIPath + "/posts"
 	tagsPath       = baseAPIPath + "/tags"
 	usersPath      = baseAPIPath + "/users"
 
-	entitiesPerPage = 10
+	entitiesPerPage    = 10
+	maxEntitiesPerPage = 100
 )
 
 type Client struct {
-	cl      *http.Client
-	baseURL string
+	cl          *http.Client
+	dl          *http.Client
+	baseURL     string
+	perPage     int
+	concurrency int
+	auth        func(*http.Request)
+	context     string
+	// Entity types fetched by GetAll and GetChanged, nil for all.
+	entities map[string]bool
+	cache    *httpCache
+	offline  bool
+
+	indexMu  sync.Mutex
+	index    *Index
+	indexErr error
+
+	maxPages      int
+	maxEntities   int
+	deadline      time.Time
+	truncationsMu sync.Mutex
+	truncations   []Truncation
+
+	retry      retryPolicy
+	rateLimit  float64
+	rateBurst  int
+	limitersMu sync.Mutex
+	limiters   map[string]*tokenBucket
 }
 
 type NewClientOpt func(*Client)
@@ -35,12 +67,53 @@ func WithTimeout(t time.Duration) NewClientOpt {
 	}
 }
 
+// Sets the per_page parameter of paginated requests. The REST API allows at
+// most 100 entities per page.
+func WithPerPage(n int) NewClientOpt {
+	return func(c *Client) {
+		if n < 1 {
+			n = 1
+		}
+		if n > maxEntitiesPerPage {
+			n = maxEntitiesPerPage
+		}
+		c.perPage = n
+	}
+}
+
+// Sets the number of pages fetched in parallel.
+func WithConcurrency(n int) NewClientOpt {
+	return func(c *Client) {
+		if n < 1 {
+			n = 1
+		}
+		c.concurrency = n
+	}
+}
+
+// Restricts GetAll and GetChanged to the given entity types, e.g.
+// EntityPosts. EntityCollections selects the discovered custom post types
+// and taxonomies.
+func WithEntities(entities ...string) NewClientOpt {
+	return func(c *Client) {
+		c.entities = make(map[string]bool, len(entities))
+		for _, e := range entities {
+			c.entities[e] = true
+		}
+	}
+}
+
 func NewClient(baseURL string, opts ...NewClientOpt) *Client {
 	cl := &Client{
 		cl: &http.Client{
 			Timeout: 10 * time.Second,
 		},
-		baseURL: baseURL,
+		// Media files can be large, so downloads only rely on the context
+		// for cancellation.
+		dl:          &http.Client{},
+		baseURL:     baseURL,
+		perPage:     entitiesPerPage,
+		concurrency: 1,
 	}
 	for _, opt := range opts {
 		opt(cl)
@@ -53,169 +126,271 @@ func (c *Client) GetAll(ctx context.Context) (*SiteContent, error) {
 		err     error
 		content *SiteContent = &SiteContent{}
 	)
-	if content.Categories, err = c.GetCategories(ctx); err != nil {
-		return nil, fmt.Errorf("failed to get categories: %w", err)
+	if c.fetches(EntityCategories) {
+		if content.Categories, err = c.GetCategories(ctx); err != nil {
+			return nil, fmt.Errorf("failed to get categories: %w", err)
+		}
 	}
-	if content.Comments, err = c.GetComments(ctx); err != nil {
-		return nil, fmt.Errorf("failed to get comments: %w", err)
+	if c.fetches(EntityComments) {
+		if content.Comments, err = c.GetComments(ctx); err != nil {
+			return nil, fmt.Errorf("failed to get comments: %w", err)
+		}
 	}
-	if content.Pages, err = c.GetPages(ctx); err != nil {
-		return nil, fmt.Errorf("failed to get pages: %w", err)
+	if c.fetches(EntityMedia) {
+		if content.Media, err = c.GetMedia(ctx); err != nil {
+			return nil, fmt.Errorf("failed to get media: %w", err)
+		}
 	}
-	if content.Posts, err = c.GetPosts(ctx); err != nil {
-		return nil, fmt.Errorf("failed to get posts: %w", err)
+	if c.fetches(EntityPages) {
+		if content.Pages, err = c.GetPages(ctx); err != nil {
+			return nil, fmt.Errorf("failed to get pages: %w", err)
+		}
 	}
-	if content.Tags, err = c.GetTags(ctx); err != nil {
-		return nil, fmt.Errorf("failed to get tags: %w", err)
+	if c.fetches(EntityPosts) {
+		if content.Posts, err = c.GetPosts(ctx); err != nil {
+			return nil, fmt.Errorf("failed to get posts: %w", err)
+		}
+	}
+	if c.fetches(EntityTags) {
+		if content.Tags, err = c.GetTags(ctx); err != nil {
+			return nil, fmt.Errorf("failed to get tags: %w", err)
+		}
 	}
-	if content.Users, err = c.GetUsers(ctx); err != nil {
-		return nil, fmt.Errorf("failed to get users: %w", err)
+	if c.fetches(EntityUsers) {
+		if content.Users, err = c.GetUsers(ctx); err != nil {
+			return nil, fmt.Errorf("failed to get users: %w", err)
+		}
+	}
+	if c.fetches(EntityCollections) {
+		if content.Collections, content.Extra, err = c.GetDiscovered(ctx); err != nil {
+			return nil, fmt.Errorf("failed to get custom collections: %w", err)
+		}
 	}
+	c.resolveTimes(ctx, content)
+	content.Site = c.siteInfo(ctx)
+	content.Truncated = c.Truncations()
 	return content, nil
 }
 
+// Returns true if the entity type is selected with WithEntities.
+func (c *Client) fetches(entity string) bool {
+	return c.entities == nil || c.entities[entity]
+}
+
 func (c *Client) GetCategories(ctx context.Context) ([]Category, error) {
-	categories := make([]Category, 0)
-	if err := c.paginatedRequest(ctx, c.baseURL+categoriesPath, func(b []byte) (int, error) {
-		var cats []Category
-		if err := json.Unmarshal(b, &cats); err != nil {
-			return 0, fmt.Errorf("failed to unmarshal categories: %w", err)
-		}
-		categories = append(categories, cats...)
-		return len(cats), nil
-	}); err != nil {
-		return nil, fmt.Errorf("failed to get categories: %w", err)
-	}
-	return categories, nil
+	return List[Category](ctx, c, categoriesPath, c.listOptions(EntityCategories))
 }
 
 func (c *Client) GetComments(ctx context.Context) ([]Comment, error) {
-	comments := make([]Comment, 0)
-	if err := c.paginatedRequest(ctx, c.baseURL+commentsPath, func(b []byte) (int, error) {
-		var comms []Comment
-		if err := json.Unmarshal(b, &comms); err != nil {
-			return 0, fmt.Errorf("failed to unmarshal comments: %w", err)
-		}
-		comments = append(comments, comms...)
-		return len(comms), nil
-	}); err != nil {
-		return nil, fmt.Errorf("failed to get comments: %w", err)
-	}
-	return comments, nil
+	return List[Comment](ctx, c, commentsPath, c.listOptions(EntityComments))
+}
+
+func (c *Client) GetMedia(ctx context.Context) ([]Media, error) {
+	return List[Media](ctx, c, mediaPath, c.listOptions(EntityMedia))
 }
 
 func (c *Client) GetPages(ctx context.Context) ([]Page, error) {
-	pages := make([]Page, 0)
-	if err := c.paginatedRequest(ctx, c.baseURL+pagesPath, func(b []byte) (int, error) {
-		var pgs []Page
-		if err := json.Unmarshal(b, &pgs); err != nil {
-			return 0, fmt.Errorf("failed to unmarshal pages: %w", err)
-		}
-		pages = append(pages, pgs...)
-		return len(pgs), nil
-	}); err != nil {
-		return nil, fmt.Errorf("failed to get pages: %w", err)
-	}
-	return pages, nil
+	return List[Page](ctx, c, pagesPath, c.listOptions(EntityPages))
 }
 
 func (c *Client) GetPosts(ctx context.Context) ([]Post, error) {
-	posts := make([]Post, 0)
-	if err := c.paginatedRequest(ctx, c.baseURL+postsPath, func(b []byte) (int, error) {
-		var pst []Post
-		if err := json.Unmarshal(b, &pst); err != nil {
-			return 0, fmt.Errorf("failed to unmarshal posts: %w", err)
-		}
-		posts = append(posts, pst...)
-		return len(pst), nil
-	}); err != nil {
-		return nil, fmt.Errorf("failed to get posts: %w", err)
-	}
-	return posts, nil
+	return List[Post](ctx, c, postsPath, c.listOptions(EntityPosts))
 }
 
 func (c *Client) GetTags(ctx context.Context) ([]Tag, error) {
-	tags := make([]Tag, 0)
-	if err := c.paginatedRequest(ctx, c.baseURL+tagsPath, func(b []byte) (int, error) {
-		var tgs []Tag
-		if err := json.Unmarshal(b, &tgs); err != nil {
-			return 0, fmt.Errorf("failed to unmarshal tags: %w", err)
-		}
-		tags = append(tags, tgs...)
-		return len(tgs), nil
-	}); err != nil {
-		return nil, fmt.Errorf("failed to get tags: %w", err)
-	}
-	return tags, nil
+	return List[Tag](ctx, c, tagsPath, c.listOptions(EntityTags))
 }
 
 func (c *Client) GetUsers(ctx context.Context) ([]User, error) {
-	users := make([]User, 0)
-	if err := c.paginatedRequest(ctx, c.baseURL+usersPath, func(b []byte) (int, error) {
-		var usrs []User
-		if err := json.Unmarshal(b, &usrs); err != nil {
-			return 0, fmt.Errorf("failed to unmarshal users: %w", err)
-		}
-		users = append(users, usrs...)
-		return len(usrs), nil
-	}); err != nil {
-		return nil, fmt.Errorf("failed to get users: %w", err)
-	}
-	return users, nil
+	return List[User](ctx, c, usersPath, c.listOptions(EntityUsers))
 }
 
-func (c *Client) paginatedRequest(ctx context.Context, path string, forEach func([]byte) (int, error)) error {
-	for page := 1; ; page++ {
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
-		if err != nil {
-			return fmt.Errorf("failed to create HTTP request: %w", err)
-		}
-
-		q := req.URL.Query()
-		q.Add("per_page", fmt.Sprint(entitiesPerPage))
-		q.Add("page", fmt.Sprint(page))
-		req.URL.RawQuery = q.Encode()
+func (c *Client) paginatedRequest(ctx context.Context, path string, query url.Values, forEach func([]byte) (int, error)) error {
+	b, header, err := c.getPage(ctx, path, query, 1)
+	if err != nil || b == nil {
+		return err
+	}
+	entities, err := forEach(b)
+	if err != nil {
+		return fmt.Errorf("failed to process response body: %w", err)
+	}
 
-		log.Printf("paginated HTTP request page: %d, %s %s", page, req.Method, req.URL.String())
+	totalPages, err := strconv.Atoi(header.Get("X-WP-TotalPages"))
+	if err != nil {
+		log.Printf("no valid X-WP-TotalPages header, fetching pages sequentially")
+		return c.sequentialPages(ctx, path, query, entities, forEach)
+	}
+	log.Printf("got %s entities in %d pages", header.Get("X-WP-Total"), totalPages)
 
-		res, err := c.cl.Do(req)
-		if err != nil {
-			return fmt.Errorf("failed to do HTTP request: %w", err)
+	if c.maxPages > 0 && totalPages > c.maxPages {
+		if err := c.concurrentPages(ctx, path, query, c.maxPages, forEach); err != nil {
+			return err
 		}
+		return errMaxPages
+	}
+	return c.concurrentPages(ctx, path, query, totalPages, forEach)
+}
+
+// Fetches pages 2..totalPages with c.concurrency workers, calling forEach on
+// the pages in order as soon as all the preceding pages are processed.
+func (c *Client) concurrentPages(ctx context.Context, path string, query url.Values, totalPages int, forEach func([]byte) (int, error)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-		log.Printf("got response: %#v", res)
-		defer res.Body.Close()
+	type result struct {
+		page int
+		body []byte
+		err  error
+	}
+	pages := make(chan int)
+	results := make(chan result)
 
-		switch res.StatusCode {
-		case http.StatusOK:
-			var b []byte
-			if b, err = io.ReadAll(res.Body); err != nil {
-				return fmt.Errorf("failed to read response body: %w", err)
+	go func() {
+		defer close(pages)
+		for page := 2; page <= totalPages; page++ {
+			select {
+			case pages <- page:
+			case <-ctx.Done():
+				return
 			}
-			entities, err := forEach(b)
-			if err != nil {
-				return fmt.Errorf("failed to process response body: %w", err)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < c.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for page := range pages {
+				b, _, err := c.getPage(ctx, path, query, page)
+				select {
+				case results <- result{page: page, body: b, err: err}:
+				case <-ctx.Done():
+					return
+				}
 			}
-			if entities < entitiesPerPage {
-				return nil
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	pending := make(map[int][]byte)
+	next := 2
+	for res := range results {
+		if res.err != nil {
+			return res.err
+		}
+		pending[res.page] = res.body
+		for {
+			b, ok := pending[next]
+			if !ok {
+				break
 			}
-		case http.StatusBadRequest:
-			var errRes APIErrorResponse
-			if err := json.NewDecoder(res.Body).Decode(&errRes); err != nil {
-				return fmt.Errorf("failed to decode error response: %w", err)
+			delete(pending, next)
+			next++
+			// The page is gone if entities were deleted during the crawl.
+			if b == nil {
+				continue
 			}
-			if errRes.IsInvalidPageNumber() {
-				log.Printf("got invalid page number error")
-				return nil
+			if _, err := forEach(b); err != nil {
+				return fmt.Errorf("failed to process response body: %w", err)
 			}
-			return fmt.Errorf("got bad request error: %s", errRes.Message)
-		default:
-			return fmt.Errorf("got unexpected status code: %d", res.StatusCode)
 		}
+	}
+	return ctx.Err()
+}
+
+// Used when the site does not send pagination headers, e.g. because a
+// caching proxy strips them. Walks the pages one by one until a short or
+// invalid page.
+func (c *Client) sequentialPages(ctx context.Context, path string, query url.Values, firstPageEntities int, forEach func([]byte) (int, error)) error {
+	entities := firstPageEntities
+	for page := 2; entities >= c.perPage; page++ {
+		if c.maxPages > 0 && page > c.maxPages {
+			return errMaxPages
+		}
+		b, _, err := c.getPage(ctx, path, query, page)
+		if err != nil || b == nil {
+			return err
+		}
+		if entities, err = forEach(b); err != nil {
+			return fmt.Errorf("failed to process response body: %w", err)
+		}
+	}
+	return nil
+}
+
+// Returns a nil body if the page number is out of range.
+func (c *Client) getPage(ctx context.Context, path string, query url.Values, page int) ([]byte, http.Header, error) {
+	q := url.Values{}
+	for k, vs := range query {
+		q[k] = vs
+	}
+	q.Set("per_page", fmt.Sprint(c.perPage))
+	q.Set("page", fmt.Sprint(page))
+
+	return c.get(ctx, path, q)
+}
 
-		// REMOVE AFTER TESTING
-		if page == 3 {
-			return nil
+// Unmarshals the response of a non-paginated endpoint, e.g. "/wp/v2/types".
+func (c *Client) getJSON(ctx context.Context, endpoint string, query url.Values, v any) error {
+	b, _, err := c.get(ctx, c.restURL(endpoint), query)
+	if err != nil {
+		return err
+	}
+	if b == nil {
+		return fmt.Errorf("got invalid page number error")
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return nil
+}
+
+// Returns a nil body if the API responds with an invalid page number error.
+func (c *Client) get(ctx context.Context, path string, query url.Values) ([]byte, http.Header, error) {
+	ctx, cancel := c.withDeadline(ctx)
+	defer cancel()
+	req, err := c.newRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	q := req.URL.Query()
+	for k, vs := range query {
+		for _, v := range vs {
+			q.Add(k, v)
 		}
 	}
+	if c.context != "" && !q.Has("context") {
+		q.Set("context", c.context)
+	}
+	req.URL.RawQuery = q.Encode()
+
+	log.Printf("HTTP request: %s %s", req.Method, req.URL.String())
+
+	res, err := c.cachedDo(c.cl, req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to do HTTP request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusOK {
+		b, err := io.ReadAll(res.Body)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read response body: %w", err)
+		}
+		return b, res.Header, nil
+	}
+	if res.StatusCode < http.StatusBadRequest {
+		return nil, nil, fmt.Errorf("got unexpected status code: %d", res.StatusCode)
+	}
+	apiErr := newAPIError(req, res)
+	if apiErr.IsInvalidPageNumber() {
+		log.Printf("got invalid page number error")
+		return nil, res.Header, nil
+	}
+	return nil, nil, apiErr
 }
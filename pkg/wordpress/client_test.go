@@ -0,0 +1,110 @@
+package wordpress
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type testEntity struct {
+	ID int `json:"id"`
+}
+
+// Serves total entities in pages of per_page. Later pages respond faster, so
+// that concurrently fetched pages arrive out of order.
+func newPagedServer(total int, headers bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		totalPages := (total + perPage - 1) / perPage
+		if page < 1 || page > totalPages {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"code":"rest_post_invalid_page_number","message":"The page number requested is larger than the number of pages available."}`)
+			return
+		}
+		time.Sleep(time.Duration(totalPages-page) * 10 * time.Millisecond)
+		if headers {
+			w.Header().Set("X-WP-Total", strconv.Itoa(total))
+			w.Header().Set("X-WP-TotalPages", strconv.Itoa(totalPages))
+		}
+		var entities []testEntity
+		for id := (page-1)*perPage + 1; id <= page*perPage && id <= total; id++ {
+			entities = append(entities, testEntity{ID: id})
+		}
+		b, _ := json.Marshal(entities)
+		w.Write(b)
+	}))
+}
+
+func testEntities(n int) []testEntity {
+	entities := make([]testEntity, 0, n)
+	for id := 1; id <= n; id++ {
+		entities = append(entities, testEntity{ID: id})
+	}
+	return entities
+}
+
+func TestListPagination(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		headers         bool
+		opts            []NewClientOpt
+		want            []testEntity
+		wantTruncations []Truncation
+	}{
+		{
+			name:    "concurrent",
+			headers: true,
+			opts:    []NewClientOpt{WithConcurrency(4)},
+			want:    testEntities(9),
+		},
+		{
+			name: "sequential",
+			want: testEntities(9),
+		},
+		{
+			name:            "max pages",
+			headers:         true,
+			opts:            []NewClientOpt{WithConcurrency(4), WithMaxPages(3)},
+			want:            testEntities(6),
+			wantTruncations: []Truncation{{Endpoint: EntityEndpoint(EntityPosts), Reason: TruncatedMaxPages, Fetched: 6}},
+		},
+		{
+			name:            "max pages sequential",
+			opts:            []NewClientOpt{WithMaxPages(3)},
+			want:            testEntities(6),
+			wantTruncations: []Truncation{{Endpoint: EntityEndpoint(EntityPosts), Reason: TruncatedMaxPages, Fetched: 6}},
+		},
+		{
+			name:            "max entities",
+			headers:         true,
+			opts:            []NewClientOpt{WithConcurrency(4), WithMaxEntities(5)},
+			want:            testEntities(5),
+			wantTruncations: []Truncation{{Endpoint: EntityEndpoint(EntityPosts), Reason: TruncatedMaxEntities, Fetched: 5}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newPagedServer(9, tc.headers)
+			defer srv.Close()
+
+			c := NewClient(srv.URL, append([]NewClientOpt{WithPerPage(2)}, tc.opts...)...)
+			got, err := List[testEntity](context.Background(), c, EntityEndpoint(EntityPosts), &ListOptions{})
+			if err != nil {
+				t.Fatalf("List() failed: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("List() mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantTruncations, c.Truncations()); diff != "" {
+				t.Errorf("Truncations() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package wordpress
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+const (
+	indexPath      = "/"
+	typesPath      = baseAPIPath + "/types"
+	taxonomiesPath = baseAPIPath + "/taxonomies"
+
+	defaultRESTNamespace = "wp/v2"
+
+	CollectionKindType     = "type"
+	CollectionKindTaxonomy = "taxonomy"
+)
+
+// The built-in post types and taxonomies that have their own fields in
+// SiteContent.
+var builtinCollections = map[string]bool{
+	CollectionKindType + "/post":         true,
+	CollectionKindType + "/page":         true,
+	CollectionKindType + "/attachment":   true,
+	CollectionKindTaxonomy + "/category": true,
+	CollectionKindTaxonomy + "/post_tag": true,
+}
+
+// The REST API index served at /wp-json.
+type Index struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	URL         string           `json:"url"`
+	Home        string           `json:"home"`
+	Namespaces  []string         `json:"namespaces"`
+	Routes      map[string]Route `json:"routes"`
+	// Keyed by the name of the method, e.g. "application-passwords".
+	Authentication json.RawMessage `json:"authentication"`
+
+	// Empty if the site uses a UTC offset, which is then in GMTOffset.
+	TimezoneString string `json:"timezone_string"`
+	// Hours, e.g. "5.5". Sent as a string or a number depending on how the
+	// option was saved.
+	GMTOffset json.Number `json:"gmt_offset"`
+}
+
+type Route struct {
+	Namespace string   `json:"namespace"`
+	Methods   []string `json:"methods"`
+}
+
+type PostType struct {
+	Name          string   `json:"name"`
+	Slug          string   `json:"slug"`
+	Description   string   `json:"description"`
+	Hierarchical  bool     `json:"hierarchical"`
+	Taxonomies    []string `json:"taxonomies"`
+	RestBase      string   `json:"rest_base"`
+	RestNamespace string   `json:"rest_namespace"`
+}
+
+type Taxonomy struct {
+	Name          string   `json:"name"`
+	Slug          string   `json:"slug"`
+	Description   string   `json:"description"`
+	Hierarchical  bool     `json:"hierarchical"`
+	Types         []string `json:"types"`
+	RestBase      string   `json:"rest_base"`
+	RestNamespace string   `json:"rest_namespace"`
+}
+
+// A custom post type or taxonomy collection found through discovery. If the
+// discovery itself fails, a single collection without an endpoint records its
+// error, so that the snapshot tells that custom collections may be missing.
+type Collection struct {
+	Kind     string `json:"kind"`
+	Slug     string `json:"slug"`
+	Name     string `json:"name"`
+	Endpoint string `json:"endpoint"`
+	Count    int    `json:"count"`
+	// Set if the collection could not be crawled, e.g. because it requires
+	// authentication.
+	Error string `json:"error,omitempty"`
+}
+
+// The name of the file the collection is stored in by SiteContent.Marshal.
+func (c *Collection) FileName() string {
+	return "extra." + strings.ReplaceAll(strings.Trim(c.Endpoint, "/"), "/", ".") + ".json"
+}
+
+// Returns the REST API index. It is only fetched once per client, and so is
+// a failure to fetch it returned again, unless the context was canceled. The
+// index must not be modified.
+func (c *Client) GetIndex(ctx context.Context) (*Index, error) {
+	c.indexMu.Lock()
+	defer c.indexMu.Unlock()
+	if c.index != nil || c.indexErr != nil {
+		return c.index, c.indexErr
+	}
+	var idx Index
+	if err := c.getJSON(ctx, indexPath, nil, &idx); err != nil {
+		err = fmt.Errorf("failed to get REST index: %w", err)
+		if ctx.Err() == nil {
+			c.indexErr = err
+		}
+		return nil, err
+	}
+	c.index = &idx
+	return c.index, nil
+}
+
+func (c *Client) GetPostTypes(ctx context.Context) (map[string]PostType, error) {
+	types := make(map[string]PostType)
+	if err := c.getJSON(ctx, typesPath, nil, &types); err != nil {
+		return nil, fmt.Errorf("failed to get post types: %w", err)
+	}
+	return types, nil
+}
+
+func (c *Client) GetTaxonomies(ctx context.Context) (map[string]Taxonomy, error) {
+	taxonomies := make(map[string]Taxonomy)
+	if err := c.getJSON(ctx, taxonomiesPath, nil, &taxonomies); err != nil {
+		return nil, fmt.Errorf("failed to get taxonomies: %w", err)
+	}
+	return taxonomies, nil
+}
+
+// Returns the collections of the post types and taxonomies registered on the
+// site, except for the built-in ones. Only collections with a route in the
+// REST index are returned.
+func (c *Client) DiscoverCollections(ctx context.Context) ([]Collection, error) {
+	idx, err := c.GetIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	types, err := c.GetPostTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	taxonomies, err := c.GetTaxonomies(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var collections []Collection
+	add := func(kind, slug, name, namespace, restBase string) {
+		if builtinCollections[kind+"/"+slug] || restBase == "" {
+			return
+		}
+		if namespace == "" {
+			namespace = defaultRESTNamespace
+		}
+		endpoint := "/" + namespace + "/" + restBase
+		if _, ok := idx.Routes[endpoint]; !ok {
+			log.Printf("skipping %s %s, route %s is not in the REST index", kind, slug, endpoint)
+			return
+		}
+		collections = append(collections, Collection{
+			Kind:     kind,
+			Slug:     slug,
+			Name:     name,
+			Endpoint: endpoint,
+		})
+	}
+	for slug, t := range types {
+		add(CollectionKindType, slug, t.Name, t.RestNamespace, t.RestBase)
+	}
+	for slug, t := range taxonomies {
+		add(CollectionKindTaxonomy, slug, t.Name, t.RestNamespace, t.RestBase)
+	}
+	sort.Slice(collections, func(i, j int) bool {
+		return collections[i].Endpoint < collections[j].Endpoint
+	})
+	return collections, nil
+}
+
+// Discovers and crawls the custom collections. The entities are kept as raw
+// JSON keyed by endpoint, as their schema is not known in advance. See
+// crawlDiscovered for how failures are handled.
+func (c *Client) GetDiscovered(ctx context.Context) ([]Collection, map[string][]json.RawMessage, error) {
+	extra := make(map[string][]json.RawMessage)
+	collections, err := c.crawlDiscovered(ctx, func(col *Collection, opts *ListOptions) (int, error) {
+		entities, err := List[json.RawMessage](ctx, c, col.Endpoint, opts)
+		if err != nil {
+			return 0, err
+		}
+		extra[col.Endpoint] = entities
+		return len(entities), nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return collections, extra, nil
+}
+
+// Discovers the custom collections and crawls each one with crawl, which
+// returns the number of entities crawled. A collection that cannot be
+// crawled does not fail the others, its error is recorded in the returned
+// collection instead. Neither does a failed discovery fail the crawl of the
+// built-in entities, it is recorded as a collection without an endpoint. Only
+// a canceled context is returned as an error.
+func (c *Client) crawlDiscovered(ctx context.Context, crawl func(col *Collection, opts *ListOptions) (int, error)) ([]Collection, error) {
+	collections, err := c.DiscoverCollections(ctx)
+	if reason := c.budgetReason(ctx, err); reason != "" {
+		c.truncate(EntityCollections, reason, 0)
+		return nil, nil
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		log.Printf("failed to discover custom collections: %v", err)
+		return discoveryFailed(err), nil
+	}
+	for i := range collections {
+		col := &collections[i]
+		// Custom post types take the same statuses as posts.
+		opts := &ListOptions{}
+		if col.Kind == CollectionKindType {
+			opts = c.listOptions(EntityPosts)
+		}
+		n, err := crawl(col, opts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Printf("failed to crawl %s %s: %v", col.Kind, col.Slug, err)
+			col.Error = err.Error()
+			continue
+		}
+		col.Count = n
+	}
+	return collections, nil
+}
+
+func discoveryFailed(err error) []Collection {
+	return []Collection{{Error: "failed to discover custom collections: " + err.Error()}}
+}
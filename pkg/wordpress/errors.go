@@ -0,0 +1,101 @@
+package wordpress
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Sentinels matched by APIErrorResponse with errors.Is, e.g.
+// errors.Is(err, ErrForbidden).
+var (
+	// 401, or the credentials were rejected.
+	ErrUnauthorized = errors.New("unauthorized")
+	// 403, or the endpoint is not accessible with the given credentials,
+	// e.g. because a security plugin restricts the REST API.
+	ErrForbidden = errors.New("forbidden")
+	// The route does not exist, e.g. because a plugin disabled it.
+	ErrNoRoute = errors.New("no route")
+	// 404 of an existing route, e.g. the entity was deleted.
+	ErrNotFound = errors.New("not found")
+	// The page is past the last page of a collection.
+	ErrInvalidPageNumber = errors.New("invalid page number")
+	// 5xx, the site itself is failing.
+	ErrServerError = errors.New("server error")
+)
+
+// Read at most this much of an error response.
+const maxErrorBodySize = 64 << 10
+
+// The error body of the REST API, returned as an error for 4xx and 5xx
+// responses. Code and Message are empty if the body is not a REST API
+// error, e.g. an HTML error page of a proxy.
+type APIErrorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+	Data    struct {
+		Status int `json:"status"`
+	} `json:"data,omitempty"`
+
+	// Taken from the request and response, not the body.
+	URL        string `json:"-"`
+	StatusCode int    `json:"-"`
+}
+
+func newAPIError(req *http.Request, res *http.Response) *APIErrorResponse {
+	e := &APIErrorResponse{URL: req.URL.String(), StatusCode: res.StatusCode}
+	if b, err := io.ReadAll(io.LimitReader(res.Body, maxErrorBodySize)); err == nil {
+		// Not every error response is JSON, the status alone still
+		// classifies it.
+		json.Unmarshal(b, e)
+	}
+	return e
+}
+
+func (e *APIErrorResponse) Error() string {
+	msg := fmt.Sprintf("got unexpected status code: %d from %s", e.StatusCode, e.URL)
+	if e.Code != "" {
+		msg += fmt.Sprintf(", %s: %s", e.Code, e.Message)
+	}
+	if errors.Is(e, ErrUnauthorized) || errors.Is(e, ErrForbidden) {
+		msg += ", the credentials may be missing, invalid or lack the required capabilities"
+	}
+	return msg
+}
+
+func (e *APIErrorResponse) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || authErrorCodes[e.Code]
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden || e.Code == "rest_forbidden" || strings.HasPrefix(e.Code, "rest_cannot_")
+	case ErrNoRoute:
+		return e.Code == "rest_no_route"
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound && e.Code != "rest_no_route"
+	case ErrInvalidPageNumber:
+		return e.IsInvalidPageNumber()
+	case ErrServerError:
+		return e.StatusCode >= 500
+	}
+	return false
+}
+
+func (e *APIErrorResponse) IsInvalidPageNumber() bool {
+	return e.Code == "rest_post_invalid_page_number"
+}
+
+// Codes of rejected credentials, sent by WordPress core and the common
+// authentication plugins, some of them with other statuses than 401.
+var authErrorCodes = map[string]bool{
+	"rest_not_logged_in":        true,
+	"rest_cookie_invalid_nonce": true,
+	"invalid_username":          true,
+	"invalid_email":             true,
+	"incorrect_password":        true,
+	"jwt_auth_invalid_token":    true,
+	"jwt_auth_bad_auth_header":  true,
+}
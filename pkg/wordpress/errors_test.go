@@ -0,0 +1,33 @@
+package wordpress
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestAPIErrorIs(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		err    *APIErrorResponse
+		target error
+		want   bool
+	}{
+		{"deleted post", &APIErrorResponse{StatusCode: 404, Code: "rest_post_invalid_id"}, ErrNotFound, true},
+		{"not found without body", &APIErrorResponse{StatusCode: 404}, ErrNotFound, true},
+		{"no route is not not found", &APIErrorResponse{StatusCode: 404, Code: "rest_no_route"}, ErrNotFound, false},
+		{"no route", &APIErrorResponse{StatusCode: 404, Code: "rest_no_route"}, ErrNoRoute, true},
+		{"forbidden", &APIErrorResponse{StatusCode: 403}, ErrForbidden, true},
+		{"cannot read", &APIErrorResponse{StatusCode: 401, Code: "rest_cannot_read"}, ErrForbidden, true},
+		{"unauthorized", &APIErrorResponse{StatusCode: 400, Code: "incorrect_password"}, ErrUnauthorized, true},
+		{"server error", &APIErrorResponse{StatusCode: 502}, ErrServerError, true},
+		{"client error", &APIErrorResponse{StatusCode: 400}, ErrServerError, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := fmt.Errorf("failed to update entity 1: %w", tc.err)
+			if got := errors.Is(err, tc.target); got != tc.want {
+				t.Errorf("errors.Is(%v, %v) = %v, want %v", err, tc.target, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package wordpress
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/antchfx/htmlquery"
+)
+
+const (
+	// Stores the SiteInfo of a snapshot, see SiteContent.Site.
+	SiteFile = "site.json"
+
+	PluginSourceNamespace = "namespace"
+	PluginSourceGenerator = "generator"
+	PluginSourceAssets    = "assets"
+	PluginSourceReadme    = "readme"
+
+	readmePath     = "/readme.html"
+	pluginsPath    = "/wp-content/plugins/"
+	pluginReadme   = "readme.txt"
+	wordpressName  = "WordPress"
+	generatorXPath = "//meta[@name='generator']/@content"
+)
+
+// Namespaces registered by WordPress itself.
+var coreNamespaces = map[string]bool{
+	"wp":              true,
+	"oembed":          true,
+	"wp-site-health":  true,
+	"wp-block-editor": true,
+	"wp-abilities":    true,
+}
+
+// Plugin slugs of the namespace vendors and generator names that differ from
+// them. Other vendors and names are used as slugs as they are.
+var pluginSlugs = map[string]string{
+	"yoast":              "wordpress-seo",
+	"rankmath":           "seo-by-rank-math",
+	"aioseo":             "all-in-one-seo-pack",
+	"wc":                 "woocommerce",
+	"wc-analytics":       "woocommerce",
+	"wc-admin":           "woocommerce",
+	"litespeed":          "litespeed-cache",
+	"ithemes-security":   "better-wp-security",
+	"wpforms":            "wpforms-lite",
+	"site kit by google": "google-site-kit",
+	"wpml":               "sitepress-multilingual-cms",
+	"slider revolution":  "revslider",
+}
+
+var (
+	pluginAssetRe   = regexp.MustCompile(`/wp-content/plugins/([A-Za-z0-9_.-]+)/`)
+	feedGeneratorRe = regexp.MustCompile(`<generator>https?://wordpress\.org/\?v=([0-9.]+)</generator>`)
+	readmeVersionRe = regexp.MustCompile(`(?i)<br\s*/?>\s*version\s+([0-9]+(?:\.[0-9]+)+)`)
+	stableTagRe     = regexp.MustCompile(`(?im)^\s*stable tag:\s*([0-9][^\s]*)`)
+)
+
+// Describes the site itself, so that changes to its configuration, plugins
+// and API surface show up between snapshots.
+type SiteInfo struct {
+	Name           string      `json:"name"`
+	Description    string      `json:"description"`
+	URL            string      `json:"url"`
+	Home           string      `json:"home"`
+	TimezoneString string      `json:"timezone_string,omitempty"`
+	GMTOffset      json.Number `json:"gmt_offset,omitempty"`
+	Namespaces     []string    `json:"namespaces"`
+	// Methods keyed by route.
+	Routes map[string][]string `json:"routes"`
+	// Names of the authentication methods announced in the index, e.g.
+	// "application-passwords".
+	Authentication []string `json:"authentication,omitempty"`
+	// Set if the REST index could not be fetched, e.g. because the site
+	// blocks it. The fields above are empty then.
+	IndexError string `json:"index_error,omitempty"`
+
+	// Empty if the site does not disclose its version.
+	Version string `json:"version,omitempty"`
+	// Where the version was found, one of the PluginSource* constants
+	// except PluginSourceNamespace.
+	VersionSource string   `json:"version_source,omitempty"`
+	Plugins       []Plugin `json:"plugins,omitempty"`
+}
+
+// A plugin detected on the site. Only plugins that expose themselves, e.g.
+// with a REST namespace or assets on the home page, are detected.
+type Plugin struct {
+	Slug string `json:"slug"`
+	// Empty if the plugin does not disclose its version.
+	Version string `json:"version,omitempty"`
+	// The PluginSource* constants the plugin was detected through.
+	Sources []string `json:"sources"`
+}
+
+// Fingerprints the site from its REST index, home page, feed and readme
+// files. Every source is best-effort, so that sites blocking the REST API are
+// fingerprinted too. Only a canceled context is an error.
+func (c *Client) GetSiteInfo(ctx context.Context) (*SiteInfo, error) {
+	info := &SiteInfo{}
+	if idx, err := c.GetIndex(ctx); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		log.Printf("fingerprinting the site without its REST index: %v", err)
+		info.IndexError = err.Error()
+	} else {
+		info.fromIndex(idx)
+	}
+
+	plugins := make(map[string]*Plugin)
+	detect := func(slug, version, source string) {
+		p, ok := plugins[slug]
+		if !ok {
+			p = &Plugin{Slug: slug}
+			plugins[slug] = p
+		}
+		if p.Version == "" {
+			p.Version = version
+		}
+		for _, s := range p.Sources {
+			if s == source {
+				return
+			}
+		}
+		p.Sources = append(p.Sources, source)
+	}
+	for _, ns := range info.Namespaces {
+		vendor := strings.SplitN(ns, "/", 2)[0]
+		if !coreNamespaces[vendor] {
+			detect(pluginSlug(vendor), "", PluginSourceNamespace)
+		}
+	}
+
+	home := info.Home
+	if home == "" {
+		home = c.baseURL
+	}
+	if b, err := c.fetch(ctx, home); err != nil {
+		log.Printf("failed to get the home page, skipping its fingerprint: %v", err)
+	} else {
+		generators, err := generators(b)
+		if err != nil {
+			log.Printf("failed to parse the home page: %v", err)
+		}
+		for _, g := range generators {
+			name, version := splitGenerator(g)
+			if name == wordpressName {
+				info.Version, info.VersionSource = version, PluginSourceGenerator
+				continue
+			}
+			detect(pluginSlug(name), version, PluginSourceGenerator)
+		}
+		for _, m := range pluginAssetRe.FindAllSubmatch(b, -1) {
+			detect(string(m[1]), "", PluginSourceAssets)
+		}
+	}
+	if info.Version == "" {
+		if b, err := c.fetch(ctx, c.baseURL+feedPath); err == nil {
+			if m := feedGeneratorRe.FindSubmatch(b); m != nil {
+				info.Version, info.VersionSource = string(m[1]), PluginSourceGenerator
+			}
+		}
+	}
+	if info.Version == "" {
+		if b, err := c.fetch(ctx, c.baseURL+readmePath); err == nil {
+			if m := readmeVersionRe.FindSubmatch(b); m != nil {
+				info.Version, info.VersionSource = string(m[1]), PluginSourceReadme
+			}
+		}
+	}
+
+	for _, p := range plugins {
+		if p.Version != "" {
+			continue
+		}
+		b, err := c.fetch(ctx, c.baseURL+pluginsPath+p.Slug+"/"+pluginReadme)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		if m := stableTagRe.FindSubmatch(b); m != nil {
+			p.Version = string(m[1])
+			p.Sources = append(p.Sources, PluginSourceReadme)
+		}
+	}
+	for _, p := range plugins {
+		info.Plugins = append(info.Plugins, *p)
+	}
+	sort.Slice(info.Plugins, func(i, j int) bool {
+		return info.Plugins[i].Slug < info.Plugins[j].Slug
+	})
+	return info, nil
+}
+
+func (info *SiteInfo) fromIndex(idx *Index) {
+	info.Name = idx.Name
+	info.Description = idx.Description
+	info.URL = idx.URL
+	info.Home = idx.Home
+	info.TimezoneString = idx.TimezoneString
+	info.GMTOffset = idx.GMTOffset
+	info.Namespaces = append([]string(nil), idx.Namespaces...)
+	sort.Strings(info.Namespaces)
+	info.Routes = make(map[string][]string, len(idx.Routes))
+	for path, route := range idx.Routes {
+		info.Routes[path] = route.Methods
+	}
+	var methods map[string]json.RawMessage
+	// Sites without any send an empty array instead of an object.
+	if json.Unmarshal(idx.Authentication, &methods) == nil {
+		for name := range methods {
+			info.Authentication = append(info.Authentication, name)
+		}
+		sort.Strings(info.Authentication)
+	}
+}
+
+// Returns the site info of the content, logging instead of failing the crawl
+// if it cannot be fetched.
+func (c *Client) siteInfo(ctx context.Context) *SiteInfo {
+	info, err := c.GetSiteInfo(ctx)
+	if err != nil {
+		log.Printf("failed to fingerprint the site: %v", err)
+		return nil
+	}
+	return info
+}
+
+func generators(page []byte) ([]string, error) {
+	doc, err := htmlquery.Parse(bytes.NewReader(page))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+	var values []string
+	for _, n := range htmlquery.Find(doc, generatorXPath) {
+		values = append(values, htmlquery.InnerText(n))
+	}
+	return values, nil
+}
+
+// Splits a generator, e.g. "WordPress 6.4.2" or "Elementor 3.18.3; features:
+// ...", into the name and the version, if any.
+func splitGenerator(g string) (string, string) {
+	g = strings.TrimSpace(strings.SplitN(g, ";", 2)[0])
+	i := strings.LastIndex(g, " ")
+	if i < 0 || !strings.ContainsAny(g[i+1:i+2], "0123456789") {
+		return g, ""
+	}
+	return strings.TrimSpace(g[:i]), g[i+1:]
+}
+
+func pluginSlug(name string) string {
+	name = strings.ToLower(name)
+	if slug, ok := pluginSlugs[name]; ok {
+		return slug
+	}
+	return strings.ReplaceAll(name, " ", "-")
+}
@@ -0,0 +1,210 @@
+package wordpress
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	wpDateLayout = "2006-01-02T15:04:05"
+
+	// The REST API compares the modified_after/after filters against the
+	// site-local date columns. WordPress 5.3 and later convert the filters to
+	// the site's timezone, older versions ignore their offset. Going back
+	// this far covers every possible UTC offset; the overlapping entities are
+	// deduplicated by ID when merged.
+	checkpointOverlap = 24 * time.Hour
+)
+
+// Checkpoint records the state of the last crawl of a site so that the next
+// crawl only needs to fetch the entities changed since then.
+type Checkpoint struct {
+	// Path prefix of the full snapshot the checkpoint was taken from.
+	Snapshot string `json:"snapshot"`
+	// Latest modified_gmt (date_gmt for comments) seen per entity type.
+	Modified  map[string]string `json:"modified_gmt"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+func (c *SiteContent) Checkpoint(snapshot string) *Checkpoint {
+	cp := &Checkpoint{
+		Snapshot:  snapshot,
+		Modified:  make(map[string]string),
+		CreatedAt: time.Now().UTC(),
+	}
+	for _, p := range c.Posts {
+		if p.ModifiedGMT > cp.Modified[EntityPosts] {
+			cp.Modified[EntityPosts] = p.ModifiedGMT
+		}
+	}
+	for _, p := range c.Pages {
+		if p.ModifiedGMT > cp.Modified[EntityPages] {
+			cp.Modified[EntityPages] = p.ModifiedGMT
+		}
+	}
+	for _, m := range c.Media {
+		if m.ModifiedGMT > cp.Modified[EntityMedia] {
+			cp.Modified[EntityMedia] = m.ModifiedGMT
+		}
+	}
+	for _, cm := range c.Comments {
+		if cm.DateGMT > cp.Modified[EntityComments] {
+			cp.Modified[EntityComments] = cm.DateGMT
+		}
+	}
+	return cp
+}
+
+// Fetches the posts, pages, media and comments changed since the checkpoint.
+// Entity types without date filters in the REST API (categories, tags and
+// users) and the custom collections are always fetched in full.
+func (c *Client) GetChanged(ctx context.Context, cp *Checkpoint) (*SiteContent, error) {
+	var (
+		err     error
+		content *SiteContent = &SiteContent{}
+	)
+	if c.fetches(EntityCategories) {
+		if content.Categories, err = c.GetCategories(ctx); err != nil {
+			return nil, fmt.Errorf("failed to get categories: %w", err)
+		}
+	}
+	if c.fetches(EntityComments) {
+		if content.Comments, err = List[Comment](ctx, c, commentsPath, c.sinceOptions(EntityComments, cp)); err != nil {
+			return nil, fmt.Errorf("failed to get comments: %w", err)
+		}
+	}
+	if c.fetches(EntityMedia) {
+		if content.Media, err = List[Media](ctx, c, mediaPath, c.sinceOptions(EntityMedia, cp)); err != nil {
+			return nil, fmt.Errorf("failed to get media: %w", err)
+		}
+	}
+	if c.fetches(EntityPages) {
+		if content.Pages, err = List[Page](ctx, c, pagesPath, c.sinceOptions(EntityPages, cp)); err != nil {
+			return nil, fmt.Errorf("failed to get pages: %w", err)
+		}
+	}
+	if c.fetches(EntityPosts) {
+		if content.Posts, err = List[Post](ctx, c, postsPath, c.sinceOptions(EntityPosts, cp)); err != nil {
+			return nil, fmt.Errorf("failed to get posts: %w", err)
+		}
+	}
+	if c.fetches(EntityTags) {
+		if content.Tags, err = c.GetTags(ctx); err != nil {
+			return nil, fmt.Errorf("failed to get tags: %w", err)
+		}
+	}
+	if c.fetches(EntityUsers) {
+		if content.Users, err = c.GetUsers(ctx); err != nil {
+			return nil, fmt.Errorf("failed to get users: %w", err)
+		}
+	}
+	if c.fetches(EntityCollections) {
+		if content.Collections, content.Extra, err = c.GetDiscovered(ctx); err != nil {
+			return nil, fmt.Errorf("failed to get custom collections: %w", err)
+		}
+	}
+	c.resolveTimes(ctx, content)
+	content.Site = c.siteInfo(ctx)
+	content.Truncated = c.Truncations()
+	return content, nil
+}
+
+// Comments are filtered by their date, the other entities by their modified
+// date.
+func (c *Client) sinceOptions(entity string, cp *Checkpoint) *ListOptions {
+	opts := c.listOptions(entity)
+	t := parseDate(cp.Modified[entity], time.UTC)
+	if t.IsZero() {
+		return opts
+	}
+	if entity == EntityComments {
+		opts.After = t.Add(-checkpointOverlap)
+	} else {
+		opts.ModifiedAfter = t.Add(-checkpointOverlap)
+	}
+	return opts
+}
+
+// Merges the entities returned by GetChanged into the content. Posts, pages,
+// media and comments replace the existing entities with the same ID, while the
+// fully fetched entity types and the truncations replace the existing ones.
+func (c *SiteContent) Merge(changes *SiteContent) {
+	c.Categories = changes.Categories
+	c.Tags = changes.Tags
+	c.Users = changes.Users
+	c.Collections = changes.Collections
+	c.Extra = changes.Extra
+	c.Truncated = changes.Truncated
+	c.Site = changes.Site
+	c.Comments = mergeByID(c.Comments, changes.Comments, func(cm Comment) int { return cm.ID })
+	c.Media = mergeByID(c.Media, changes.Media, func(m Media) int { return m.ID })
+	c.Pages = mergeByID(c.Pages, changes.Pages, func(p Page) int { return p.ID })
+	c.Posts = mergeByID(c.Posts, changes.Posts, func(p Post) int { return p.ID })
+}
+
+func mergeByID[T any](base, changes []T, id func(T) int) []T {
+	index := make(map[int]int, len(base))
+	for i, e := range base {
+		index[id(e)] = i
+	}
+	for _, e := range changes {
+		if i, ok := index[id(e)]; ok {
+			base[i] = e
+			continue
+		}
+		index[id(e)] = len(base)
+		base = append(base, e)
+	}
+	return base
+}
+
+// Removes the posts, pages, media and comments deleted from the site since
+// the content was crawled, which GetChanged cannot tell. Only the IDs of the
+// entities are fetched.
+func (c *Client) PruneDeleted(ctx context.Context, content *SiteContent) error {
+	ids := make(map[string]map[int]bool)
+	for _, entity := range []string{EntityComments, EntityMedia, EntityPages, EntityPosts} {
+		if !c.fetches(entity) {
+			continue
+		}
+		opts := c.listOptions(entity)
+		opts.Fields = []string{"id"}
+		ids[entity] = make(map[int]bool)
+		if err := Each(ctx, c, EntityEndpoint(entity), opts, func(e struct {
+			ID int `json:"id"`
+		}) error {
+			ids[entity][e.ID] = true
+			return nil
+		}); err != nil {
+			return fmt.Errorf("failed to get %s IDs: %w", entity, err)
+		}
+		// An incomplete list would prune entities that still exist.
+		if c.truncated(EntityEndpoint(entity)) {
+			delete(ids, entity)
+		}
+	}
+	if exists, ok := ids[EntityComments]; ok {
+		content.Comments = retainByID(content.Comments, exists, func(cm Comment) int { return cm.ID })
+	}
+	if exists, ok := ids[EntityMedia]; ok {
+		content.Media = retainByID(content.Media, exists, func(m Media) int { return m.ID })
+	}
+	if exists, ok := ids[EntityPages]; ok {
+		content.Pages = retainByID(content.Pages, exists, func(p Page) int { return p.ID })
+	}
+	if exists, ok := ids[EntityPosts]; ok {
+		content.Posts = retainByID(content.Posts, exists, func(p Post) int { return p.ID })
+	}
+	return nil
+}
+
+func retainByID[T any](entities []T, exists map[int]bool, id func(T) int) []T {
+	kept := entities[:0]
+	for _, e := range entities {
+		if exists[id(e)] {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,160 @@
+package wordpress
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Query options of collection endpoints. Not every endpoint supports every
+// option, e.g. terms have no status.
+type ListOptions struct {
+	// Any additional query parameters, e.g. "categories", "parent" or
+	// "author".
+	Filters url.Values
+	OrderBy string
+	// "asc" or "desc".
+	Order  string
+	Status []string
+	Search string
+	// Limits the response to the given fields (_fields), which reduces the
+	// response size when only a few fields are needed.
+	Fields []string
+
+	// Date filters (after, before, modified_after and modified_before),
+	// ignored if zero. They are sent with their offset, which WordPress
+	// converts to the site's timezone. Terms and users have no dates, and
+	// comments have no modified date.
+	After          time.Time
+	Before         time.Time
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+}
+
+func (o *ListOptions) query() url.Values {
+	q := url.Values{}
+	if o == nil {
+		return q
+	}
+	for k, vs := range o.Filters {
+		q[k] = append([]string(nil), vs...)
+	}
+	if o.OrderBy != "" {
+		q.Set("orderby", o.OrderBy)
+	}
+	if o.Order != "" {
+		q.Set("order", o.Order)
+	}
+	if len(o.Status) > 0 {
+		q.Set("status", strings.Join(o.Status, ","))
+	}
+	if o.Search != "" {
+		q.Set("search", o.Search)
+	}
+	if len(o.Fields) > 0 {
+		q.Set("_fields", strings.Join(o.Fields, ","))
+	}
+	for param, t := range map[string]time.Time{
+		"after":           o.After,
+		"before":          o.Before,
+		"modified_after":  o.ModifiedAfter,
+		"modified_before": o.ModifiedBefore,
+	} {
+		if !t.IsZero() {
+			q.Set(param, t.Format(time.RFC3339))
+		}
+	}
+	return q
+}
+
+// Calls fn for every entity of a collection endpoint, e.g. "/wp/v2/posts",
+// in order. Only the pages currently being fetched are held in memory. If a
+// crawl budget runs out, the entities are cut short without an error and the
+// truncation is recorded, see Client.Truncations.
+func Each[T any](ctx context.Context, c *Client, endpoint string, opts *ListOptions, fn func(T) error) error {
+	n := 0
+	err := c.paginatedRequest(ctx, c.restURL(endpoint), opts.query(), func(b []byte) (int, error) {
+		var entities []T
+		if err := json.Unmarshal(b, &entities); err != nil {
+			return 0, fmt.Errorf("failed to unmarshal entities: %w", err)
+		}
+		for _, e := range entities {
+			if c.maxEntities > 0 && n >= c.maxEntities {
+				return 0, errMaxEntities
+			}
+			if err := fn(e); err != nil {
+				return 0, err
+			}
+			n++
+		}
+		return len(entities), nil
+	})
+	if reason := c.budgetReason(ctx, err); reason != "" {
+		c.truncate(endpoint, reason, n)
+		return nil
+	}
+	return err
+}
+
+// Returns all entities of a collection endpoint.
+func List[T any](ctx context.Context, c *Client, endpoint string, opts *ListOptions) ([]T, error) {
+	entities := make([]T, 0)
+	if err := Each(ctx, c, endpoint, opts, func(e T) error {
+		entities = append(entities, e)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return entities, nil
+}
+
+// Sends the entities of a collection endpoint to the returned channel, which
+// is closed when done. The error channel then receives the result of the
+// crawl. The caller must either drain the entity channel or cancel the
+// context.
+func Stream[T any](ctx context.Context, c *Client, endpoint string, opts *ListOptions) (<-chan T, <-chan error) {
+	entities := make(chan T)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		err := Each(ctx, c, endpoint, opts, func(e T) error {
+			select {
+			case entities <- e:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		close(entities)
+		errc <- err
+	}()
+	return entities, errc
+}
+
+// Returns the collection endpoint of one of the built-in entity types, e.g.
+// "/wp/v2/posts" for EntityPosts.
+func EntityEndpoint(entity string) string {
+	return baseAPIPath + "/" + entity
+}
+
+// Returns the default list options for one of the built-in entity types.
+func (c *Client) listOptions(entity string) *ListOptions {
+	opts := &ListOptions{}
+	if c.context != contextEdit {
+		return opts
+	}
+	switch entity {
+	case EntityPosts, EntityPages:
+		opts.Status = []string{postStatusAny}
+	case EntityComments:
+		opts.Status = []string{commentStatusAll}
+	}
+	return opts
+}
+
+func (c *Client) restURL(endpoint string) string {
+	return c.baseURL + restPrefix + endpoint
+}
@@ -0,0 +1,112 @@
+package wordpress
+
+import (
+	"sort"
+	"time"
+)
+
+// ManifestFile is the name of the manifest in a snapshot.
+const ManifestFile = "manifest.json"
+
+// Manifest lists the entities of a snapshot, so that the next crawl can tell
+// which entities were deleted without downloading the whole snapshot.
+type Manifest struct {
+	Snapshot  string    `json:"snapshot"`
+	CreatedAt time.Time `json:"created_at"`
+	// Keyed by entity type. Entity types that were not crawled are omitted.
+	Entities map[string][]ManifestEntry `json:"entities"`
+}
+
+type ManifestEntry struct {
+	ID   int    `json:"id"`
+	Slug string `json:"slug,omitempty"`
+}
+
+// Tombstone records an entity that was deleted from the site.
+type Tombstone struct {
+	Entity string `json:"entity"`
+	ID     int    `json:"id"`
+	Slug   string `json:"slug,omitempty"`
+	// Path prefix of the last snapshot containing the entity.
+	LastSeen string `json:"last_seen"`
+}
+
+// Returns the manifest of the given entity types of the content, or of all
+// built-in entity types if none are given. Truncated entity types are left
+// out, as their missing entities were not deleted.
+func (c *SiteContent) Manifest(snapshot string, entities ...string) *Manifest {
+	if len(entities) == 0 {
+		entities = []string{EntityCategories, EntityComments, EntityMedia, EntityPages, EntityPosts, EntityTags, EntityUsers}
+	}
+	m := &Manifest{
+		Snapshot:  snapshot,
+		CreatedAt: time.Now().UTC(),
+		Entities:  make(map[string][]ManifestEntry),
+	}
+	truncated := make(map[string]bool, len(c.Truncated))
+	for _, t := range c.Truncated {
+		truncated[t.Endpoint] = true
+	}
+	for _, entity := range entities {
+		if truncated[EntityEndpoint(entity)] {
+			continue
+		}
+		var entries []ManifestEntry
+		switch entity {
+		case EntityCategories:
+			entries = manifestEntries(c.Categories, func(e Category) ManifestEntry { return ManifestEntry{e.ID, e.Slug} })
+		case EntityComments:
+			entries = manifestEntries(c.Comments, func(e Comment) ManifestEntry { return ManifestEntry{ID: e.ID} })
+		case EntityMedia:
+			entries = manifestEntries(c.Media, func(e Media) ManifestEntry { return ManifestEntry{e.ID, e.Slug} })
+		case EntityPages:
+			entries = manifestEntries(c.Pages, func(e Page) ManifestEntry { return ManifestEntry{e.ID, e.Slug} })
+		case EntityPosts:
+			entries = manifestEntries(c.Posts, func(e Post) ManifestEntry { return ManifestEntry{e.ID, e.Slug} })
+		case EntityTags:
+			entries = manifestEntries(c.Tags, func(e Tag) ManifestEntry { return ManifestEntry{e.ID, e.Slug} })
+		case EntityUsers:
+			entries = manifestEntries(c.Users, func(e User) ManifestEntry { return ManifestEntry{e.ID, e.Slug} })
+		default:
+			continue
+		}
+		m.Entities[entity] = entries
+	}
+	return m
+}
+
+func manifestEntries[T any](entities []T, entry func(T) ManifestEntry) []ManifestEntry {
+	entries := make([]ManifestEntry, 0, len(entities))
+	for _, e := range entities {
+		entries = append(entries, entry(e))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries
+}
+
+// Returns the entities of the previous manifest missing from this one,
+// ordered by entity type and ID. Only the entity types listed in both
+// manifests are compared.
+func (m *Manifest) Deletions(prev *Manifest) []Tombstone {
+	entities := make([]string, 0, len(prev.Entities))
+	for entity := range prev.Entities {
+		if _, ok := m.Entities[entity]; ok {
+			entities = append(entities, entity)
+		}
+	}
+	sort.Strings(entities)
+
+	tombstones := make([]Tombstone, 0)
+	for _, entity := range entities {
+		exists := make(map[int]bool, len(m.Entities[entity]))
+		for _, e := range m.Entities[entity] {
+			exists[e.ID] = true
+		}
+		for _, e := range prev.Entities[entity] {
+			if !exists[e.ID] {
+				tombstones = append(tombstones, Tombstone{Entity: entity, ID: e.ID, Slug: e.Slug, LastSeen: prev.Snapshot})
+			}
+		}
+	}
+	return tombstones
+}
@@ -0,0 +1,161 @@
+package wordpress
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Maps media IDs to their files archived into a snapshot, see MediaObjects.
+const MediaObjectsFile = "media-objects.json"
+
+type Media struct {
+	ID           int           `json:"id"`
+	Date         string        `json:"date"`
+	DateGMT      string        `json:"date_gmt"`
+	Modified     string        `json:"modified"`
+	ModifiedGMT  string        `json:"modified_gmt"`
+	Slug         string        `json:"slug"`
+	Status       string        `json:"status"`
+	Type         string        `json:"type"`
+	Link         string        `json:"link"`
+	Title        RenderedField `json:"title"`
+	Caption      RenderedField `json:"caption"`
+	Description  RenderedField `json:"description"`
+	Author       int           `json:"author"`
+	AltText      string        `json:"alt_text"`
+	MediaType    string        `json:"media_type"`
+	MimeType     string        `json:"mime_type"`
+	Post         int           `json:"post"`
+	SourceURL    string        `json:"source_url"`
+	MediaDetails struct {
+		Width    int                  `json:"width,omitempty"`
+		Height   int                  `json:"height,omitempty"`
+		File     string               `json:"file,omitempty"`
+		Filesize int64                `json:"filesize,omitempty"`
+		Sizes    map[string]MediaSize `json:"sizes,omitempty"`
+	} `json:"media_details"`
+
+	// Parsed dates, see Post.DateTime.
+	DateTime     time.Time `json:"-"`
+	ModifiedTime time.Time `json:"-"`
+
+	// Original JSON, see Post.JSON.
+	JSON json.RawMessage `json:"-"`
+}
+
+type MediaSize struct {
+	File      string `json:"file"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Filesize  int64  `json:"filesize,omitempty"`
+	MimeType  string `json:"mime_type"`
+	SourceURL string `json:"source_url"`
+}
+
+// Returns the URLs of the original file and all registered sizes, keyed by
+// size name. The original file is keyed by "original".
+func (m *Media) FileURLs() map[string]string {
+	urls := map[string]string{"original": m.SourceURL}
+	for name, size := range m.MediaDetails.Sizes {
+		if size.SourceURL != "" {
+			urls[name] = size.SourceURL
+		}
+	}
+	return urls
+}
+
+// Downloads a file from the site, e.g. a media file. The caller must close
+// the response body. The crawl deadline applies until the body is closed.
+func (c *Client) Download(ctx context.Context, fileURL string) (*http.Response, error) {
+	if c.offline {
+		return nil, fmt.Errorf("%w: %s", ErrNotCached, fileURL)
+	}
+	ctx, cancel := c.withDeadline(ctx)
+	req, err := c.newRequest(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	log.Printf("download HTTP request: %s %s", req.Method, req.URL.String())
+
+	res, err := c.do(c.dl, req)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to do HTTP request: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		defer cancel()
+		defer res.Body.Close()
+		if res.StatusCode >= http.StatusBadRequest {
+			return nil, newAPIError(req, res)
+		}
+		return nil, fmt.Errorf("got unexpected status code: %d", res.StatusCode)
+	}
+	res.Body = &cancelOnClose{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+// Releases the context of a download when its body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// A media file archived into a snapshot. Files that could not be archived
+// only have their source URL and the error.
+type ArchivedFile struct {
+	Key       string `json:"key,omitempty"`
+	SourceURL string `json:"source_url"`
+	SHA256    string `json:"sha256,omitempty"`
+	Size      int64  `json:"size,omitempty"`
+	Error     string `json:"error,omitempty"`
+	// The Media.ModifiedGMT the file was archived at. Later snapshots copy
+	// the file instead of downloading it as long as the media is not
+	// modified.
+	ModifiedGMT string `json:"modified_gmt,omitempty"`
+}
+
+// Keyed by size name, the original file is keyed by "original".
+type ArchivedMedia map[string]ArchivedFile
+
+// The archived files of the media of a snapshot keyed by media ID, stored as
+// media-objects.json.
+type MediaObjects map[int]ArchivedMedia
+
+// Reads the media objects of a snapshot's files. Snapshots taken without
+// media have none.
+func UnmarshalMediaObjects(files map[string][]byte) (MediaObjects, error) {
+	objects := make(MediaObjects)
+	b, ok := files[MediaObjectsFile]
+	if !ok {
+		return objects, nil
+	}
+	if err := json.Unmarshal(b, &objects); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal media objects: %w", err)
+	}
+	return objects, nil
+}
+
+// Returns the successfully archived files keyed by their source URL.
+func (o MediaObjects) BySourceURL() map[string]ArchivedFile {
+	archived := make(map[string]ArchivedFile)
+	for _, sizes := range o {
+		for _, f := range sizes {
+			if f.Key != "" && f.Error == "" {
+				archived[f.SourceURL] = f
+			}
+		}
+	}
+	return archived
+}
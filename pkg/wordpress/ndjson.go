@@ -0,0 +1,108 @@
+package wordpress
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+)
+
+const ndjsonExt = ".ndjson"
+
+// Opens the destination of a stream of entities, e.g. an object upload. The
+// name is the file name of the entity type in the snapshot, e.g.
+// "posts.ndjson". If the stream fails, the writer is closed with
+// CloseWithError if it has that method, so that a partial upload can be
+// aborted.
+type StreamOpener func(name string) (io.WriteCloser, error)
+
+// Writes every entity of a collection endpoint to w as newline-delimited
+// JSON while the pages are being fetched, and returns the number of
+// entities. The entities are written as returned by the API.
+func WriteNDJSON(ctx context.Context, c *Client, endpoint string, opts *ListOptions, w io.Writer) (int, error) {
+	var (
+		n   int
+		buf bytes.Buffer
+	)
+	err := Each(ctx, c, endpoint, opts, func(e json.RawMessage) error {
+		buf.Reset()
+		if err := json.Compact(&buf, e); err != nil {
+			return fmt.Errorf("failed to compact entity: %w", err)
+		}
+		buf.WriteByte('\n')
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
+
+// Like GetAll, but streams each entity type as newline-delimited JSON into
+// its own writer instead of holding the entities in memory. The discovered
+// collections are returned with their counts and errors, to be stored as
+// collections.json.
+func (c *Client) StreamAll(ctx context.Context, open StreamOpener) ([]Collection, error) {
+	for _, entity := range []string{EntityCategories, EntityComments, EntityMedia, EntityPages, EntityPosts, EntityTags, EntityUsers} {
+		if !c.fetches(entity) {
+			continue
+		}
+		n, err := c.stream(ctx, open, entity+ndjsonExt, EntityEndpoint(entity), c.listOptions(entity))
+		if err != nil {
+			return nil, fmt.Errorf("failed to stream %s: %w", entity, err)
+		}
+		log.Printf("streamed %d %s", n, entity)
+	}
+	if !c.fetches(EntityCollections) {
+		return nil, nil
+	}
+	return c.crawlDiscovered(ctx, func(col *Collection, opts *ListOptions) (int, error) {
+		return c.stream(ctx, open, col.streamFileName(), col.Endpoint, opts)
+	})
+}
+
+func (c *Client) stream(ctx context.Context, open StreamOpener, name, endpoint string, opts *ListOptions) (int, error) {
+	w, err := open(name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	n, err := WriteNDJSON(ctx, c, endpoint, opts, w)
+	if err != nil {
+		if a, ok := w.(interface{ CloseWithError(error) error }); ok {
+			a.CloseWithError(err)
+		} else {
+			w.Close()
+		}
+		return 0, err
+	}
+	if err := w.Close(); err != nil {
+		return 0, fmt.Errorf("failed to close %s: %w", name, err)
+	}
+	return n, nil
+}
+
+func (c *Collection) streamFileName() string {
+	return strings.TrimSuffix(c.FileName(), ".json") + ndjsonExt
+}
+
+// Unmarshals the entity list stored as <name>.json, or as <name>.ndjson by
+// StreamAll. Returns false if there is neither.
+func unmarshalEntities(files map[string][]byte, name string, v any) (bool, error) {
+	if b, ok := files[name+".json"]; ok {
+		return true, json.Unmarshal(b, v)
+	}
+	b, ok := files[name+ndjsonExt]
+	if !ok {
+		return false, nil
+	}
+	lines := bytes.Split(bytes.TrimSpace(b), []byte("\n"))
+	if len(lines) == 1 && len(lines[0]) == 0 {
+		lines = nil
+	}
+	array := append([]byte("["), bytes.Join(lines, []byte(","))...)
+	return true, json.Unmarshal(append(array, ']'), v)
+}
@@ -0,0 +1,54 @@
+package wordpress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Read at most this much of a probed response.
+const maxProbeBodySize = 1 << 20
+
+// The response to a Probe.
+type ProbeResponse struct {
+	URL        string
+	StatusCode int
+	Header     http.Header
+	// Truncated to the first MiB.
+	Body []byte
+}
+
+// Sends a request to a path of the site, e.g. "/xmlrpc.php", without
+// credentials and without following redirects, to check what the site
+// exposes to anyone. Error statuses are returned as a response.
+func (c *Client) Probe(ctx context.Context, method, path string, body io.Reader) (*ProbeResponse, error) {
+	ctx, cancel := c.withDeadline(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	cl := &http.Client{
+		Transport: c.cl.Transport,
+		Timeout:   c.cl.Timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	res, err := c.do(cl, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to do HTTP request: %w", err)
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(res.Body, maxProbeBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return &ProbeResponse{
+		URL:        req.URL.String(),
+		StatusCode: res.StatusCode,
+		Header:     res.Header,
+		Body:       b,
+	}, nil
+}
@@ -0,0 +1,123 @@
+package wordpress
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+)
+
+// The entities keep the JSON they were unmarshaled from and marshal back to
+// it. Each method converts the entity to a type without methods to decode or
+// encode the typed fields without recursing. The times parsed from the GMT
+// dates are set on unmarshal, the local dates need the site's timezone and
+// are resolved by the client.
+
+func (e *Category) UnmarshalJSON(b []byte) error {
+	type plain Category
+	return unmarshalKeepingJSON(b, (*plain)(e), &e.JSON)
+}
+
+func (e Category) MarshalJSON() ([]byte, error) {
+	type plain Category
+	return marshalKeptJSON(plain(e), e.JSON)
+}
+
+func (e *Comment) UnmarshalJSON(b []byte) error {
+	type plain Comment
+	if err := unmarshalKeepingJSON(b, (*plain)(e), &e.JSON); err != nil {
+		return err
+	}
+	e.DateTime = parseDate(e.DateGMT, time.UTC)
+	return nil
+}
+
+func (e Comment) MarshalJSON() ([]byte, error) {
+	type plain Comment
+	return marshalKeptJSON(plain(e), e.JSON)
+}
+
+func (e *Media) UnmarshalJSON(b []byte) error {
+	type plain Media
+	if err := unmarshalKeepingJSON(b, (*plain)(e), &e.JSON); err != nil {
+		return err
+	}
+	e.DateTime, e.ModifiedTime = parseDate(e.DateGMT, time.UTC), parseDate(e.ModifiedGMT, time.UTC)
+	return nil
+}
+
+func (e Media) MarshalJSON() ([]byte, error) {
+	type plain Media
+	return marshalKeptJSON(plain(e), e.JSON)
+}
+
+func (e *Page) UnmarshalJSON(b []byte) error {
+	type plain Page
+	if err := unmarshalKeepingJSON(b, (*plain)(e), &e.JSON); err != nil {
+		return err
+	}
+	e.DateTime, e.ModifiedTime = parseDate(e.DateGMT, time.UTC), parseDate(e.ModifiedGMT, time.UTC)
+	return nil
+}
+
+func (e Page) MarshalJSON() ([]byte, error) {
+	type plain Page
+	return marshalKeptJSON(plain(e), e.JSON)
+}
+
+func (e *Post) UnmarshalJSON(b []byte) error {
+	type plain Post
+	if err := unmarshalKeepingJSON(b, (*plain)(e), &e.JSON); err != nil {
+		return err
+	}
+	e.DateTime, e.ModifiedTime = parseDate(e.DateGMT, time.UTC), parseDate(e.ModifiedGMT, time.UTC)
+	return nil
+}
+
+func (e Post) MarshalJSON() ([]byte, error) {
+	type plain Post
+	return marshalKeptJSON(plain(e), e.JSON)
+}
+
+func (e *Tag) UnmarshalJSON(b []byte) error {
+	type plain Tag
+	return unmarshalKeepingJSON(b, (*plain)(e), &e.JSON)
+}
+
+func (e Tag) MarshalJSON() ([]byte, error) {
+	type plain Tag
+	return marshalKeptJSON(plain(e), e.JSON)
+}
+
+func (e *User) UnmarshalJSON(b []byte) error {
+	type plain User
+	return unmarshalKeepingJSON(b, (*plain)(e), &e.JSON)
+}
+
+func (e User) MarshalJSON() ([]byte, error) {
+	type plain User
+	return marshalKeptJSON(plain(e), e.JSON)
+}
+
+func unmarshalKeepingJSON(b []byte, v any, raw *json.RawMessage) error {
+	if string(b) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, b); err != nil {
+		return err
+	}
+	*raw = buf.Bytes()
+	return nil
+}
+
+// Entities built by the client, e.g. scraped ones, have no JSON and are
+// marshaled from the typed fields.
+func marshalKeptJSON(v any, raw json.RawMessage) ([]byte, error) {
+	if raw != nil {
+		return raw, nil
+	}
+	return json.Marshal(v)
+}
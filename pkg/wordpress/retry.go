@@ -0,0 +1,237 @@
+package wordpress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type retryPolicy struct {
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+}
+
+// Retries requests failing with a network error, a timeout, 429 or a 5xx
+// status up to maxRetries times, waiting an exponentially growing, jittered
+// delay between baseDelay and maxDelay. A Retry-After header sent by the
+// site takes precedence over the computed delay, a longer one than maxDelay
+// gives up. Giving up on an error status returns its response, like any
+// other error status. POST requests, which create entities, are not retried.
+func WithRetries(maxRetries int, baseDelay, maxDelay time.Duration) NewClientOpt {
+	return func(c *Client) {
+		c.retry = retryPolicy{
+			maxRetries: maxRetries,
+			baseDelay:  baseDelay,
+			maxDelay:   maxDelay,
+		}
+	}
+}
+
+// Limits the requests sent to each host with a token bucket refilling at
+// requestsPerSecond and holding at most burst tokens.
+func WithRateLimit(requestsPerSecond float64, burst int) NewClientOpt {
+	return func(c *Client) {
+		if burst < 1 {
+			burst = 1
+		}
+		c.rateLimit = requestsPerSecond
+		c.rateBurst = burst
+	}
+}
+
+// Returned when a request still fails with a network error or a timeout
+// after all retries.
+type RetryError struct {
+	URL      string
+	Attempts int
+	Err      error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("giving up on %s after %d attempts: %v", e.URL, e.Attempts, e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// Sends the request, applying the rate limit and retrying transient
+// failures. Error statuses are returned as a response, the retryable ones
+// once the retries are exhausted.
+func (c *Client) do(cl *http.Client, req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter(req.URL.Host).wait(ctx); err != nil {
+			return nil, err
+		}
+
+		r := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = req.Clone(ctx)
+			r.Body = body
+		}
+
+		res, err := cl.Do(r)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err == nil && !isRetryableStatus(res.StatusCode) {
+			return res, nil
+		}
+		// A create that failed may still have been applied, so only
+		// idempotent requests are retried, and only if their body can be
+		// replayed.
+		if !isIdempotent(req.Method) || (req.Body != nil && req.GetBody == nil) {
+			return res, err
+		}
+
+		var retryAfter time.Duration
+		statusCode := 0
+		if res != nil {
+			statusCode = res.StatusCode
+			retryAfter = parseRetryAfter(res.Header.Get("Retry-After"))
+		}
+		// Waiting longer than maxDelay as the site asks would stall the
+		// crawl, so that gives up too.
+		giveUp := attempt >= c.retry.maxRetries || (c.retry.maxDelay > 0 && retryAfter > c.retry.maxDelay)
+		if giveUp && res != nil {
+			if c.retry.maxRetries > 0 {
+				log.Printf("giving up on %s %s after %d attempts, status code: %d, retry after: %s", req.Method, req.URL.String(), attempt+1, statusCode, retryAfter)
+			}
+			return res, nil
+		}
+		if giveUp {
+			if c.retry.maxRetries == 0 {
+				return nil, err
+			}
+			return nil, &RetryError{
+				URL:      req.URL.String(),
+				Attempts: attempt + 1,
+				Err:      err,
+			}
+		}
+		if res != nil {
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		delay := c.retry.backoff(attempt)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		log.Printf("retrying %s %s in %s (attempt %d), status code: %d, error: %v", req.Method, req.URL.String(), delay, attempt+1, statusCode, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func isRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Returns a delay in [d/2, d), where d grows exponentially with the attempt.
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	d := float64(p.baseDelay) * math.Pow(2, float64(attempt))
+	if p.maxDelay > 0 && d > float64(p.maxDelay) {
+		d = float64(p.maxDelay)
+	}
+	return time.Duration(d/2 + rand.Float64()*d/2)
+}
+
+// Parses both forms of the header, delay seconds and an HTTP date.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+func (c *Client) limiter(host string) *tokenBucket {
+	if c.rateLimit <= 0 {
+		return nil
+	}
+	c.limitersMu.Lock()
+	defer c.limitersMu.Unlock()
+	if c.limiters == nil {
+		c.limiters = make(map[string]*tokenBucket)
+	}
+	b, ok := c.limiters[host]
+	if !ok {
+		b = &tokenBucket{
+			rate:   c.rateLimit,
+			burst:  float64(c.rateBurst),
+			tokens: float64(c.rateBurst),
+			last:   time.Now(),
+		}
+		c.limiters[host] = b
+	}
+	return b
+}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// Blocks until a token is available. A nil bucket does not limit.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
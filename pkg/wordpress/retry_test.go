@@ -0,0 +1,145 @@
+package wordpress
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetries(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		method     string
+		maxRetries int
+		// Statuses of the responses in order, the last one repeats.
+		statuses     []int
+		retryAfter   string
+		wantStatus   int
+		wantRequests int32
+	}{
+		{
+			name:         "recovers",
+			method:       http.MethodGet,
+			maxRetries:   3,
+			statuses:     []int{503, 502, 200},
+			wantStatus:   200,
+			wantRequests: 3,
+		},
+		{
+			name:         "exhausted",
+			method:       http.MethodGet,
+			maxRetries:   2,
+			statuses:     []int{503},
+			wantStatus:   503,
+			wantRequests: 3,
+		},
+		{
+			name:         "without retries",
+			method:       http.MethodGet,
+			statuses:     []int{503},
+			wantStatus:   503,
+			wantRequests: 1,
+		},
+		{
+			name:         "short retry after",
+			method:       http.MethodGet,
+			maxRetries:   3,
+			statuses:     []int{429, 200},
+			retryAfter:   "0",
+			wantStatus:   200,
+			wantRequests: 2,
+		},
+		{
+			name:         "retry after longer than the maximum delay",
+			method:       http.MethodGet,
+			maxRetries:   3,
+			statuses:     []int{429, 200},
+			retryAfter:   "3600",
+			wantStatus:   429,
+			wantRequests: 1,
+		},
+		{
+			name:         "create",
+			method:       http.MethodPost,
+			maxRetries:   3,
+			statuses:     []int{503, 200},
+			wantStatus:   503,
+			wantRequests: 1,
+		},
+		{
+			name:         "not retryable",
+			method:       http.MethodGet,
+			maxRetries:   3,
+			statuses:     []int{404, 200},
+			wantStatus:   404,
+			wantRequests: 1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(atomic.AddInt32(&requests, 1))
+				if n > len(tc.statuses) {
+					n = len(tc.statuses)
+				}
+				if tc.retryAfter != "" {
+					w.Header().Set("Retry-After", tc.retryAfter)
+				}
+				w.WriteHeader(tc.statuses[n-1])
+			}))
+			defer srv.Close()
+
+			c := NewClient(srv.URL, WithRetries(tc.maxRetries, time.Millisecond, time.Second))
+			res, err := c.Probe(context.Background(), tc.method, "/", nil)
+			if err != nil {
+				t.Fatalf("Probe() failed: %v", err)
+			}
+			if res.StatusCode != tc.wantStatus {
+				t.Errorf("Probe() status = %d, want %d", res.StatusCode, tc.wantStatus)
+			}
+			if got := atomic.LoadInt32(&requests); got != tc.wantRequests {
+				t.Errorf("Probe() sent %d requests, want %d", got, tc.wantRequests)
+			}
+		})
+	}
+}
+
+func TestRetriesExhaustedStatusIsAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	_, err := NewClient(srv.URL, WithRetries(1, time.Millisecond, time.Second)).Count(context.Background(), "/wp/v2/posts", nil)
+	var apiErr *APIErrorResponse
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway || !errors.Is(err, ErrServerError) {
+		t.Errorf("Count() error = %v, want a 502 APIErrorResponse", err)
+	}
+}
+
+func TestRetriesNetworkError(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("failed to hijack connection: %v", err)
+			return
+		}
+		conn.Close()
+	}))
+	defer srv.Close()
+
+	_, err := NewClient(srv.URL, WithRetries(2, time.Millisecond, time.Second)).Probe(context.Background(), http.MethodGet, "/", nil)
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) || retryErr.Attempts != 3 {
+		t.Errorf("Probe() error = %v, want a RetryError after 3 attempts", err)
+	}
+	if got := atomic.LoadInt32(&requests); got != 3 {
+		t.Errorf("Probe() sent %d requests, want 3", got)
+	}
+}
@@ -0,0 +1,88 @@
+package wordpress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// Errors that fetching the revisions again would not fix.
+var skippedRevisionCodes = map[string]bool{
+	// The user may not edit the post, e.g. one of another author.
+	"rest_cannot_read": true,
+	// The post was deleted.
+	"rest_post_invalid_id": true,
+}
+
+// A stored earlier version of a post or page. Revisions are immutable, so
+// they only need to be archived once.
+type Revision struct {
+	ID          int           `json:"id"`
+	Author      int           `json:"author"`
+	Date        string        `json:"date"`
+	DateGMT     string        `json:"date_gmt"`
+	Modified    string        `json:"modified"`
+	ModifiedGMT string        `json:"modified_gmt"`
+	Parent      int           `json:"parent"`
+	Slug        string        `json:"slug"`
+	Title       RenderedField `json:"title"`
+	Content     RenderedField `json:"content"`
+	Excerpt     RenderedField `json:"excerpt"`
+}
+
+// Returns the revisions of a post or page, newest first. The entity is
+// EntityPosts or EntityPages. Revisions require an authenticated client
+// that can edit the entity.
+func (c *Client) GetRevisions(ctx context.Context, entity string, id int) ([]Revision, error) {
+	return List[Revision](ctx, c, fmt.Sprintf("%s/%d/revisions", EntityEndpoint(entity), id), nil)
+}
+
+// Returns the revisions of the given posts or pages keyed by their ID,
+// fetching c.concurrency entities in parallel. An entity whose revisions
+// cannot be fetched does not stop the others, its error is returned keyed by
+// its ID instead. Entities the user may not read the revisions of, and
+// deleted ones, are skipped.
+func (c *Client) GetRevisionsOf(ctx context.Context, entity string, ids []int) (map[int][]Revision, map[int]error, error) {
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	revisions := make(map[int][]Revision, len(ids))
+	errs := make(map[int]error)
+	queue := make(chan int)
+	for i := 0; i < c.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range queue {
+				revs, err := c.GetRevisions(ctx, entity, id)
+				var apiErr *APIErrorResponse
+				if errors.As(err, &apiErr) && skippedRevisionCodes[apiErr.Code] {
+					log.Printf("skipping revisions of %s %d: %v", entity, id, err)
+					continue
+				}
+				mu.Lock()
+				if err != nil {
+					errs[id] = fmt.Errorf("failed to get revisions of %s %d: %w", entity, id, err)
+				} else if len(revs) > 0 {
+					revisions[id] = revs
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		queue <- id
+	}
+	close(queue)
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+	return revisions, errs, nil
+}
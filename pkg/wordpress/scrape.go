@@ -0,0 +1,439 @@
+package wordpress
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antchfx/htmlquery"
+	"golang.org/x/net/html"
+)
+
+// XPath expressions used to scrape posts and pages from their permalinks.
+// Each field lists alternatives, the first one matching wins. Expressions
+// may select elements or attributes.
+type Selectors struct {
+	Title    []string `json:"title"`
+	Date     []string `json:"date"`
+	Modified []string `json:"modified"`
+	Content  []string `json:"content"`
+	Excerpt  []string `json:"excerpt"`
+	// Links to the archives of the categories, tags and author of the post.
+	Categories []string `json:"categories"`
+	Tags       []string `json:"tags"`
+	Author     []string `json:"author"`
+}
+
+// Matches the markup of the default themes and the meta tags added by the
+// common SEO plugins.
+var DefaultSelectors = Selectors{
+	Title: []string{
+		`//h1[contains(@class,'entry-title')]`,
+		`//h1[contains(@class,'wp-block-post-title')]`,
+		`//meta[@property='og:title']/@content`,
+		`//title`,
+	},
+	Date: []string{
+		`//meta[@property='article:published_time']/@content`,
+		`//time[contains(@class,'published')]/@datetime`,
+		`//time[contains(@class,'entry-date')]/@datetime`,
+		`//div[contains(@class,'wp-block-post-date')]/time/@datetime`,
+	},
+	Modified: []string{
+		`//meta[@property='article:modified_time']/@content`,
+		`//time[contains(@class,'updated')]/@datetime`,
+	},
+	Content: []string{
+		`//div[contains(@class,'entry-content')]`,
+		`//div[contains(@class,'wp-block-post-content')]`,
+		`//article`,
+	},
+	Excerpt: []string{
+		`//meta[@name='description']/@content`,
+		`//meta[@property='og:description']/@content`,
+	},
+	Categories: []string{
+		`//a[contains(@rel,'category')]`,
+		`//div[contains(@class,'taxonomy-category')]//a`,
+	},
+	Tags: []string{
+		`//a[@rel='tag']`,
+		`//div[contains(@class,'taxonomy-post_tag')]//a`,
+	},
+	Author: []string{
+		`//a[@rel='author']`,
+		`//div[contains(@class,'wp-block-post-author')]//a`,
+	},
+}
+
+var (
+	shortlinkIDRegexp = regexp.MustCompile(`[?&]p=(\d+)`)
+	bodyIDRegexp      = regexp.MustCompile(`\b(?:postid|page-id)-(\d+)\b`)
+)
+
+// Returns true if the REST API index can be fetched. Hardened sites often
+// block /wp-json or serve an HTML page there.
+func (c *Client) RESTAvailable(ctx context.Context) bool {
+	if _, err := c.GetIndex(ctx); err != nil {
+		log.Printf("REST API is not available: %v", err)
+		return false
+	}
+	return true
+}
+
+// Scrapes the posts and pages of a site that does not expose the REST API.
+// The permalinks are taken from the core sitemap or the sitemap of an SEO
+// plugin, or the RSS feed if neither lists any, and the entities are
+// extracted with the selectors. Categories, tags and users are built from
+// the links found in the posts. Entities without a discoverable ID get
+// synthetic IDs, which are only unique within the returned content.
+// Permalinks that cannot be fetched, e.g. stale sitemap entries, are skipped
+// and the posts or pages are recorded as truncated. Only failing to scrape
+// any permalink is an error.
+func (c *Client) Scrape(ctx context.Context, sel Selectors) (*SiteContent, error) {
+	type target struct {
+		link     string
+		postType string
+	}
+	var targets []target
+	for _, p := range []string{wpSitemapPath, seoSitemapPath} {
+		urls, err := c.GetSitemap(ctx, c.baseURL+p)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Printf("no sitemap at %s: %v", p, err)
+			continue
+		}
+		for _, u := range urls {
+			switch t := sitemapPostType(u.Sitemap); t {
+			case "post", "page":
+				targets = append(targets, target{link: u.Loc, postType: t})
+			}
+		}
+		if len(targets) > 0 {
+			break
+		}
+		log.Printf("sitemap at %s lists no posts or pages", p)
+	}
+	if len(targets) == 0 {
+		log.Printf("falling back to the RSS feed")
+		links, err := c.GetFeedLinks(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range links {
+			targets = append(targets, target{link: l, postType: "post"})
+		}
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("found no posts or pages in the sitemap or the RSS feed")
+	}
+
+	// Fetch the permalinks with c.concurrency workers, keeping their order.
+	docs := make([]*html.Node, len(targets))
+	errs := make([]error, len(targets))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < c.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				b, err := c.fetch(ctx, targets[i].link)
+				if err == nil {
+					docs[i], err = html.Parse(bytes.NewReader(b))
+				}
+				errs[i] = err
+			}
+		}()
+	}
+	for i := range targets {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	s := &scraper{
+		sel:        sel,
+		categories: make(map[string]*Category),
+		tags:       make(map[string]*Tag),
+		users:      make(map[string]*User),
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	content := &SiteContent{}
+	failed := make(map[string]int)
+	var lastErr error
+	for i, t := range targets {
+		if errs[i] != nil {
+			log.Printf("failed to scrape %s: %v", t.link, errs[i])
+			failed[t.postType]++
+			lastErr = errs[i]
+			continue
+		}
+		p := s.post(docs[i], t.link)
+		if t.postType == "page" {
+			content.Pages = append(content.Pages, Page{
+				ID:          p.ID,
+				Date:        p.Date,
+				DateGMT:     p.DateGMT,
+				Link:        p.Link,
+				Modified:    p.Modified,
+				ModifiedGMT: p.ModifiedGMT,
+				Slug:        p.Slug,
+				Status:      p.Status,
+				Type:        "page",
+				Title:       p.Title,
+				Content:     p.Content,
+				Excerpt:     p.Excerpt,
+				Author:      p.Author,
+			})
+			continue
+		}
+		content.Posts = append(content.Posts, *p)
+	}
+	for _, link := range s.order {
+		switch {
+		case s.categories[link] != nil:
+			content.Categories = append(content.Categories, *s.categories[link])
+		case s.tags[link] != nil:
+			content.Tags = append(content.Tags, *s.tags[link])
+		case s.users[link] != nil:
+			content.Users = append(content.Users, *s.users[link])
+		}
+	}
+	if len(content.Posts)+len(content.Pages) == 0 {
+		return nil, fmt.Errorf("failed to scrape any of the %d posts and pages: %w", len(targets), lastErr)
+	}
+	for _, entity := range []struct {
+		name     string
+		postType string
+		fetched  int
+	}{
+		{EntityPages, "page", len(content.Pages)},
+		{EntityPosts, "post", len(content.Posts)},
+	} {
+		if failed[entity.postType] > 0 {
+			content.Truncated = append(content.Truncated, Truncation{
+				Endpoint: EntityEndpoint(entity.name),
+				Reason:   TruncatedFetchFailed,
+				Fetched:  entity.fetched,
+			})
+		}
+	}
+	// The scraped dates carry their offset, so no timezone is needed.
+	content.ResolveTimes(nil)
+	return content, nil
+}
+
+type scraper struct {
+	sel    Selectors
+	nextID int
+	// Terms and users keyed by their archive link, in order of appearance.
+	order      []string
+	categories map[string]*Category
+	tags       map[string]*Tag
+	users      map[string]*User
+}
+
+func (s *scraper) post(doc *html.Node, link string) *Post {
+	p := &Post{
+		ID:     s.entityID(doc),
+		Link:   link,
+		Slug:   slugFromURL(link),
+		Status: "publish",
+		Type:   "post",
+	}
+	// Like the REST API, keep the rendered fields as HTML.
+	p.Title.Rendered = html.EscapeString(strings.TrimSpace(nodeText(findFirst(doc, s.sel.Title))))
+	p.Date, p.DateGMT = parseScrapedDate(nodeText(findFirst(doc, s.sel.Date)))
+	p.Modified, p.ModifiedGMT = parseScrapedDate(nodeText(findFirst(doc, s.sel.Modified)))
+	if p.Modified == "" {
+		p.Modified, p.ModifiedGMT = p.Date, p.DateGMT
+	}
+	if n := findFirst(doc, s.sel.Content); n != nil {
+		p.Content.Rendered = strings.TrimSpace(htmlquery.OutputHTML(n, false))
+	}
+	if excerpt := strings.TrimSpace(nodeText(findFirst(doc, s.sel.Excerpt))); excerpt != "" {
+		p.Excerpt.Rendered = "<p>" + html.EscapeString(excerpt) + "</p>"
+	}
+
+	for _, a := range findAll(doc, s.sel.Categories) {
+		if cat := s.category(a); cat != nil {
+			p.Categories = appendUnique(p.Categories, cat.ID)
+		}
+	}
+	for _, a := range findAll(doc, s.sel.Tags) {
+		if tag := s.tag(a); tag != nil {
+			p.Tags = appendUnique(p.Tags, tag.ID)
+		}
+	}
+	if a := findFirst(doc, s.sel.Author); a != nil {
+		if u := s.user(a); u != nil {
+			p.Author = u.ID
+		}
+	}
+	return p
+}
+
+func (s *scraper) category(a *html.Node) *Category {
+	link := htmlquery.SelectAttr(a, "href")
+	if link == "" || s.tags[link] != nil {
+		return nil
+	}
+	cat, ok := s.categories[link]
+	if !ok {
+		cat = &Category{
+			ID:       s.syntheticID(),
+			Link:     link,
+			Name:     html.EscapeString(strings.TrimSpace(htmlquery.InnerText(a))),
+			Slug:     slugFromURL(link),
+			Taxonomy: "category",
+		}
+		s.categories[link] = cat
+		s.order = append(s.order, link)
+	}
+	cat.Count++
+	return cat
+}
+
+func (s *scraper) tag(a *html.Node) *Tag {
+	link := htmlquery.SelectAttr(a, "href")
+	// Category links have rel="category tag", so they may match tag
+	// selectors too.
+	if link == "" || s.categories[link] != nil {
+		return nil
+	}
+	tag, ok := s.tags[link]
+	if !ok {
+		tag = &Tag{
+			ID:       s.syntheticID(),
+			Link:     link,
+			Name:     html.EscapeString(strings.TrimSpace(htmlquery.InnerText(a))),
+			Slug:     slugFromURL(link),
+			Taxonomy: "post_tag",
+		}
+		s.tags[link] = tag
+		s.order = append(s.order, link)
+	}
+	tag.Count++
+	return tag
+}
+
+func (s *scraper) user(a *html.Node) *User {
+	link := htmlquery.SelectAttr(a, "href")
+	if link == "" {
+		return nil
+	}
+	u, ok := s.users[link]
+	if !ok {
+		u = &User{
+			ID:   s.syntheticID(),
+			Link: link,
+			Name: html.EscapeString(strings.TrimSpace(htmlquery.InnerText(a))),
+			Slug: slugFromURL(link),
+		}
+		s.users[link] = u
+		s.order = append(s.order, link)
+	}
+	return u
+}
+
+// Returns the real ID of the post or page from its shortlink or body class,
+// or a synthetic one.
+func (s *scraper) entityID(doc *html.Node) int {
+	if n := htmlquery.FindOne(doc, `//link[@rel='shortlink']/@href`); n != nil {
+		if m := shortlinkIDRegexp.FindStringSubmatch(nodeText(n)); m != nil {
+			if id, err := strconv.Atoi(m[1]); err == nil {
+				return id
+			}
+		}
+	}
+	if n := htmlquery.FindOne(doc, `//body/@class`); n != nil {
+		if m := bodyIDRegexp.FindStringSubmatch(nodeText(n)); m != nil {
+			if id, err := strconv.Atoi(m[1]); err == nil {
+				return id
+			}
+		}
+	}
+	return s.syntheticID()
+}
+
+// Synthetic IDs are negative so they never collide with real ones.
+func (s *scraper) syntheticID() int {
+	s.nextID--
+	return s.nextID
+}
+
+func findFirst(doc *html.Node, exprs []string) *html.Node {
+	for _, expr := range exprs {
+		n, err := htmlquery.Query(doc, expr)
+		if err != nil {
+			log.Printf("invalid selector %q: %v", expr, err)
+			continue
+		}
+		if n != nil {
+			return n
+		}
+	}
+	return nil
+}
+
+func findAll(doc *html.Node, exprs []string) []*html.Node {
+	for _, expr := range exprs {
+		nodes, err := htmlquery.QueryAll(doc, expr)
+		if err != nil {
+			log.Printf("invalid selector %q: %v", expr, err)
+			continue
+		}
+		if len(nodes) > 0 {
+			return nodes
+		}
+	}
+	return nil
+}
+
+func nodeText(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+	return htmlquery.InnerText(n)
+}
+
+// Returns the local date in the format of the REST API and its GMT
+// equivalent.
+func parseScrapedDate(s string) (string, string) {
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(s))
+	if err != nil {
+		return "", ""
+	}
+	return t.Format(wpDateLayout), t.UTC().Format(wpDateLayout)
+}
+
+func slugFromURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	return segments[len(segments)-1]
+}
+
+func appendUnique(ids []int, id int) []int {
+	for _, i := range ids {
+		if i == id {
+			return ids
+		}
+	}
+	return append(ids, id)
+}
@@ -0,0 +1,63 @@
+package wordpress
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestScrapeSkipsFailedPermalinks(t *testing.T) {
+	var srv *httptest.Server
+	pages := map[string]string{
+		"/wp-sitemap.xml": `<sitemapindex>
+			<sitemap><loc>%[1]s/wp-sitemap-posts-post-1.xml</loc></sitemap>
+			<sitemap><loc>%[1]s/wp-sitemap-posts-page-1.xml</loc></sitemap>
+		</sitemapindex>`,
+		"/wp-sitemap-posts-post-1.xml": `<urlset>
+			<url><loc>%[1]s/hello/</loc></url>
+			<url><loc>%[1]s/gone/</loc></url>
+		</urlset>`,
+		"/wp-sitemap-posts-page-1.xml": `<urlset><url><loc>%[1]s/about/</loc></url></urlset>`,
+		"/hello/":                      `<html><body><h1 class="entry-title">Hello</h1></body></html>`,
+		"/about/":                      `<html><body><h1 class="entry-title">About</h1></body></html>`,
+	}
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, body, srv.URL)
+	}))
+	defer srv.Close()
+
+	content, err := NewClient(srv.URL).Scrape(context.Background(), DefaultSelectors)
+	if err != nil {
+		t.Fatalf("Scrape() failed: %v", err)
+	}
+	var titles []string
+	for _, p := range content.Posts {
+		titles = append(titles, p.Title.Rendered)
+	}
+	for _, p := range content.Pages {
+		titles = append(titles, p.Title.Rendered)
+	}
+	if diff := cmp.Diff([]string{"Hello", "About"}, titles); diff != "" {
+		t.Errorf("Scrape() titles mismatch (-want +got):\n%s", diff)
+	}
+	want := []Truncation{{Endpoint: EntityEndpoint(EntityPosts), Reason: TruncatedFetchFailed, Fetched: 1}}
+	if diff := cmp.Diff(want, content.Truncated); diff != "" {
+		t.Errorf("Scrape() truncated mismatch (-want +got):\n%s", diff)
+	}
+
+	// Nothing left to scrape.
+	delete(pages, "/hello/")
+	delete(pages, "/about/")
+	if _, err := NewClient(srv.URL).Scrape(context.Background(), DefaultSelectors); err == nil {
+		t.Errorf("Scrape() without any reachable permalink succeeded, want error")
+	}
+}
@@ -0,0 +1,160 @@
+package wordpress
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const (
+	wpSitemapPath = "/wp-sitemap.xml"
+	feedPath      = "/feed/"
+
+	// Sitemap indexes do not nest by the protocol, but some plugins list
+	// further indexes. Deeper ones are ignored.
+	maxSitemapDepth = 3
+)
+
+// Matches the sitemaps of Yoast SEO and Rank Math, e.g. post-sitemap.xml or
+// page-sitemap2.xml.
+var seoSitemapRegexp = regexp.MustCompile(`^([a-z0-9_-]+?)-sitemap\d*\.xml$`)
+
+type SitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+	// The sitemap the URL was found in.
+	Sitemap string `xml:"-"`
+}
+
+type sitemapDocument struct {
+	XMLName  xml.Name
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+	URLs []SitemapURL `xml:"url"`
+}
+
+// Returns all URLs of a sitemap, following sitemap indexes. Sitemaps listed
+// more than once, e.g. by an index listing itself, are read once.
+func (c *Client) GetSitemap(ctx context.Context, sitemapURL string) ([]SitemapURL, error) {
+	return c.sitemap(ctx, sitemapURL, make(map[string]bool), 0)
+}
+
+func (c *Client) sitemap(ctx context.Context, sitemapURL string, visited map[string]bool, depth int) ([]SitemapURL, error) {
+	visited[sitemapURL] = true
+	b, err := c.fetch(ctx, sitemapURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sitemap %s: %w", sitemapURL, err)
+	}
+	var doc sitemapDocument
+	if err := xml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal sitemap %s: %w", sitemapURL, err)
+	}
+
+	urls := make([]SitemapURL, 0, len(doc.URLs))
+	for _, u := range doc.URLs {
+		u.Loc = strings.TrimSpace(u.Loc)
+		u.Sitemap = sitemapURL
+		urls = append(urls, u)
+	}
+	for _, sm := range doc.Sitemaps {
+		loc := strings.TrimSpace(sm.Loc)
+		if visited[loc] {
+			continue
+		}
+		if depth+1 > maxSitemapDepth {
+			log.Printf("skipping sitemap %s, nested deeper than %d indexes", loc, maxSitemapDepth)
+			continue
+		}
+		sub, err := c.sitemap(ctx, loc, visited, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, sub...)
+	}
+	return urls, nil
+}
+
+// Returns the post type of the URLs listed in a core WordPress sitemap, e.g.
+// "post" for wp-sitemap-posts-post-1.xml, or in the sitemap of an SEO
+// plugin, e.g. "page" for page-sitemap.xml. Taxonomy and author sitemaps of
+// SEO plugins yield their name, e.g. "category", and other sitemaps "".
+func sitemapPostType(sitemapURL string) string {
+	name := sitemapURL[strings.LastIndex(sitemapURL, "/")+1:]
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		name = name[:i]
+	}
+	if !strings.HasPrefix(name, "wp-sitemap-") {
+		if m := seoSitemapRegexp.FindStringSubmatch(name); m != nil {
+			return m[1]
+		}
+		return ""
+	}
+	if !strings.HasPrefix(name, "wp-sitemap-posts-") {
+		return ""
+	}
+	name = strings.TrimPrefix(name, "wp-sitemap-posts-")
+	if i := strings.LastIndex(name, "-"); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
+
+type rssDocument struct {
+	Channel struct {
+		Items []struct {
+			Link string `xml:"link"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+// Returns the permalinks of the posts in the site's RSS feed. Feeds only
+// list the latest posts, so this is a last resort when there is no sitemap.
+func (c *Client) GetFeedLinks(ctx context.Context) ([]string, error) {
+	b, err := c.fetch(ctx, c.baseURL+feedPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get feed: %w", err)
+	}
+	var doc rssDocument
+	if err := xml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal feed: %w", err)
+	}
+	links := make([]string, 0, len(doc.Channel.Items))
+	for _, item := range doc.Channel.Items {
+		links = append(links, strings.TrimSpace(item.Link))
+	}
+	return links, nil
+}
+
+// Returns the body of a non-REST URL of the site, e.g. a sitemap or page.
+// Unlike Download, it is bound by the HTTP timeout.
+func (c *Client) fetch(ctx context.Context, rawURL string) ([]byte, error) {
+	ctx, cancel := c.withDeadline(ctx)
+	defer cancel()
+	req, err := c.newRequest(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	res, err := c.cachedDo(c.cl, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to do HTTP request: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= http.StatusBadRequest {
+		return nil, newAPIError(req, res)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("got unexpected status code: %d", res.StatusCode)
+	}
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	log.Printf("fetched %s (%d bytes)", rawURL, len(b))
+	return b, nil
+}
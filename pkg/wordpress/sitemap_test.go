@@ -0,0 +1,103 @@
+package wordpress
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSitemapPostType(t *testing.T) {
+	for _, tc := range []struct {
+		sitemapURL string
+		want       string
+	}{
+		{"https://example.com/wp-sitemap-posts-post-1.xml", "post"},
+		{"https://example.com/wp-sitemap-posts-page-2.xml", "page"},
+		{"https://example.com/wp-sitemap-posts-my-type-1.xml", "my-type"},
+		{"https://example.com/wp-sitemap-taxonomies-category-1.xml", ""},
+		{"https://example.com/wp-sitemap-users-1.xml", ""},
+		{"https://example.com/post-sitemap.xml", "post"},
+		{"https://example.com/post-sitemap2.xml", "post"},
+		{"https://example.com/page-sitemap.xml?ver=2", "page"},
+		{"https://example.com/category-sitemap.xml", "category"},
+		{"https://example.com/sitemap_index.xml", ""},
+		{"https://example.com/sitemap.xml", ""},
+	} {
+		if got := sitemapPostType(tc.sitemapURL); got != tc.want {
+			t.Errorf("sitemapPostType(%q) = %q, want %q", tc.sitemapURL, got, tc.want)
+		}
+	}
+}
+
+func TestGetSitemap(t *testing.T) {
+	var srv *httptest.Server
+	sitemaps := map[string]string{
+		// Lists itself and the post sitemap twice.
+		"/sitemap_index.xml": `<sitemapindex>
+			<sitemap><loc>%[1]s/sitemap_index.xml</loc></sitemap>
+			<sitemap><loc>%[1]s/post-sitemap.xml</loc></sitemap>
+			<sitemap><loc>%[1]s/nested.xml</loc></sitemap>
+		</sitemapindex>`,
+		"/nested.xml": `<sitemapindex>
+			<sitemap><loc>%[1]s/post-sitemap.xml</loc></sitemap>
+			<sitemap><loc>%[1]s/page-sitemap.xml</loc></sitemap>
+		</sitemapindex>`,
+		"/post-sitemap.xml": `<urlset><url><loc> %[1]s/hello/ </loc><lastmod>2023-04-01</lastmod></url></urlset>`,
+		"/page-sitemap.xml": `<urlset><url><loc>%[1]s/about/</loc></url></urlset>`,
+		// Each index lists the next one.
+		"/deep-0.xml": `<sitemapindex><sitemap><loc>%[1]s/deep-1.xml</loc></sitemap></sitemapindex>`,
+		"/deep-1.xml": `<sitemapindex><sitemap><loc>%[1]s/deep-2.xml</loc></sitemap></sitemapindex>`,
+		"/deep-2.xml": `<sitemapindex><sitemap><loc>%[1]s/deep-3.xml</loc></sitemap></sitemapindex>`,
+		"/deep-3.xml": `<sitemapindex><sitemap><loc>%[1]s/deep-4.xml</loc></sitemap></sitemapindex>`,
+		"/deep-4.xml": `<urlset><url><loc>%[1]s/deep/</loc></url></urlset>`,
+	}
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := sitemaps[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, body, srv.URL)
+	}))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		name    string
+		path    string
+		want    []SitemapURL
+		wantErr bool
+	}{
+		{
+			name: "cyclic index",
+			path: "/sitemap_index.xml",
+			want: []SitemapURL{
+				{Loc: srv.URL + "/hello/", LastMod: "2023-04-01", Sitemap: srv.URL + "/post-sitemap.xml"},
+				{Loc: srv.URL + "/about/", Sitemap: srv.URL + "/page-sitemap.xml"},
+			},
+		},
+		{
+			name: "too deep",
+			path: "/deep-0.xml",
+			want: []SitemapURL{},
+		},
+		{
+			name:    "missing",
+			path:    "/wp-sitemap.xml",
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NewClient(srv.URL).GetSitemap(context.Background(), srv.URL+tc.path)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("GetSitemap() error = %v, want error: %t", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("GetSitemap() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
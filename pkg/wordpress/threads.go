@@ -0,0 +1,170 @@
+package wordpress
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+)
+
+const commentThreadsDir = "comments/"
+
+// A comment with its replies, in the order readers saw them.
+type CommentThread struct {
+	Comment
+	// Set if the comment is a reply, but its parent is not in the content,
+	// e.g. because it is held for moderation, was deleted or was filtered
+	// out. The comment is then a root of the post's threads.
+	Orphaned bool             `json:"orphaned,omitempty"`
+	Replies  []*CommentThread `json:"replies,omitempty"`
+}
+
+// Builds the comment threads of each post, keyed by post ID. The roots and
+// replies are ordered by date. Replies whose parent is missing, belongs to
+// another post or is part of a parent cycle become orphaned roots, and are
+// returned separately too.
+func BuildCommentThreads(comments []Comment) (map[int][]*CommentThread, []Comment) {
+	nodes := make(map[int]*CommentThread, len(comments))
+	for _, c := range comments {
+		nodes[c.ID] = &CommentThread{Comment: c}
+	}
+
+	threads := make(map[int][]*CommentThread)
+	var orphans []Comment
+	orphan := func(n *CommentThread) {
+		n.Orphaned = true
+		threads[n.Post] = append(threads[n.Post], n)
+		orphans = append(orphans, n.Comment)
+	}
+	for _, c := range comments {
+		n := nodes[c.ID]
+		if c.Parent == 0 {
+			threads[c.Post] = append(threads[c.Post], n)
+			continue
+		}
+		parent, ok := nodes[c.Parent]
+		if !ok || parent.Post != c.Post || parent == n {
+			orphan(n)
+			continue
+		}
+		parent.Replies = append(parent.Replies, n)
+	}
+
+	// Comments in a parent cycle are not reachable from any root. Break each
+	// cycle at its lowest ID.
+	reachable := make(map[int]bool, len(comments))
+	var visit func(n *CommentThread)
+	visit = func(n *CommentThread) {
+		reachable[n.ID] = true
+		for _, r := range n.Replies {
+			visit(r)
+		}
+	}
+	for _, roots := range threads {
+		for _, n := range roots {
+			visit(n)
+		}
+	}
+	ids := make([]int, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		if reachable[id] {
+			continue
+		}
+		n := nodes[id]
+		parent := nodes[n.Parent]
+		for i, r := range parent.Replies {
+			if r == n {
+				parent.Replies = append(parent.Replies[:i], parent.Replies[i+1:]...)
+				break
+			}
+		}
+		orphan(n)
+		visit(n)
+	}
+
+	for _, roots := range threads {
+		sortThreads(roots)
+	}
+	return threads, orphans
+}
+
+func sortThreads(threads []*CommentThread) {
+	sort.SliceStable(threads, func(i, j int) bool {
+		if !threads[i].DateTime.Equal(threads[j].DateTime) {
+			return threads[i].DateTime.Before(threads[j].DateTime)
+		}
+		return threads[i].ID < threads[j].ID
+	})
+	for _, t := range threads {
+		sortThreads(t.Replies)
+	}
+}
+
+// Returns the comment threads of each post as comments/<post-id>.json
+// files.
+func (c *SiteContent) marshalCommentThreads() (map[string][]byte, error) {
+	threads, orphans := BuildCommentThreads(c.Comments)
+	if len(orphans) > 0 {
+		log.Printf("found %d orphaned comments", len(orphans))
+	}
+	files := make(map[string][]byte, len(threads))
+	for post, roots := range threads {
+		b, err := json.Marshal(roots)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal comment threads of post %d: %w", post, err)
+		}
+		files[fmt.Sprintf("%s%d.json", commentThreadsDir, post)] = b
+	}
+	return files, nil
+}
+
+type threadFields struct {
+	Orphaned bool             `json:"orphaned,omitempty"`
+	Replies  []*CommentThread `json:"replies,omitempty"`
+}
+
+// Marshals the comment with the thread fields added, which the promoted
+// Comment.MarshalJSON would drop.
+func (t CommentThread) MarshalJSON() ([]byte, error) {
+	comment, err := json.Marshal(t.Comment)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := json.Marshal(threadFields{Orphaned: t.Orphaned, Replies: t.Replies})
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 2 {
+		return comment, nil
+	}
+	if len(comment) == 2 {
+		return fields, nil
+	}
+	return append(append(comment[:len(comment)-1:len(comment)-1], ','), fields[1:]...), nil
+}
+
+func (t *CommentThread) UnmarshalJSON(b []byte) error {
+	var fields threadFields
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	var comment map[string]json.RawMessage
+	if err := json.Unmarshal(b, &comment); err != nil {
+		return err
+	}
+	delete(comment, "orphaned")
+	delete(comment, "replies")
+	b, err := json.Marshal(comment)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, &t.Comment); err != nil {
+		return err
+	}
+	t.Orphaned, t.Replies = fields.Orphaned, fields.Replies
+	return nil
+}
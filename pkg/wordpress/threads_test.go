@@ -0,0 +1,186 @@
+package wordpress
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type threadShape struct {
+	ID       int
+	Orphaned bool
+	Replies  []threadShape
+}
+
+func shapes(threads []*CommentThread) []threadShape {
+	var s []threadShape
+	for _, t := range threads {
+		s = append(s, threadShape{ID: t.ID, Orphaned: t.Orphaned, Replies: shapes(t.Replies)})
+	}
+	return s
+}
+
+func testComment(id, post, parent, minute int) Comment {
+	return Comment{ID: id, Post: post, Parent: parent, DateTime: time.Date(2024, 1, 1, 0, minute, 0, 0, time.UTC)}
+}
+
+func TestBuildCommentThreads(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		comments    []Comment
+		want        map[int][]threadShape
+		wantOrphans []int
+	}{
+		{
+			name: "nested and ordered by date",
+			comments: []Comment{
+				testComment(1, 10, 0, 2),
+				testComment(2, 10, 0, 1),
+				testComment(3, 10, 1, 5),
+				testComment(4, 10, 1, 3),
+				testComment(5, 10, 4, 4),
+				testComment(6, 20, 0, 0),
+			},
+			want: map[int][]threadShape{
+				10: {
+					{ID: 2},
+					{ID: 1, Replies: []threadShape{
+						{ID: 4, Replies: []threadShape{{ID: 5}}},
+						{ID: 3},
+					}},
+				},
+				20: {{ID: 6}},
+			},
+		},
+		{
+			name: "same date ordered by ID",
+			comments: []Comment{
+				testComment(3, 10, 0, 0),
+				testComment(1, 10, 0, 0),
+				testComment(2, 10, 0, 0),
+			},
+			want: map[int][]threadShape{10: {{ID: 1}, {ID: 2}, {ID: 3}}},
+		},
+		{
+			name: "missing parent",
+			comments: []Comment{
+				testComment(1, 10, 0, 0),
+				testComment(2, 10, 99, 1),
+				testComment(3, 10, 2, 2),
+			},
+			want: map[int][]threadShape{
+				10: {
+					{ID: 1},
+					{ID: 2, Orphaned: true, Replies: []threadShape{{ID: 3}}},
+				},
+			},
+			wantOrphans: []int{2},
+		},
+		{
+			name: "parent on another post",
+			comments: []Comment{
+				testComment(1, 10, 0, 0),
+				testComment(2, 20, 1, 1),
+			},
+			want: map[int][]threadShape{
+				10: {{ID: 1}},
+				20: {{ID: 2, Orphaned: true}},
+			},
+			wantOrphans: []int{2},
+		},
+		{
+			name:        "own parent",
+			comments:    []Comment{testComment(1, 10, 1, 0)},
+			want:        map[int][]threadShape{10: {{ID: 1, Orphaned: true}}},
+			wantOrphans: []int{1},
+		},
+		{
+			name: "cycle of two",
+			comments: []Comment{
+				testComment(1, 10, 0, 0),
+				testComment(3, 10, 2, 1),
+				testComment(2, 10, 3, 2),
+			},
+			want: map[int][]threadShape{
+				10: {
+					{ID: 1},
+					{ID: 2, Orphaned: true, Replies: []threadShape{{ID: 3}}},
+				},
+			},
+			wantOrphans: []int{2},
+		},
+		{
+			name: "cycle of three with a reply",
+			comments: []Comment{
+				testComment(7, 10, 6, 0),
+				testComment(6, 10, 5, 0),
+				testComment(5, 10, 7, 0),
+				testComment(8, 10, 6, 1),
+			},
+			want: map[int][]threadShape{
+				10: {
+					{ID: 5, Orphaned: true, Replies: []threadShape{
+						{ID: 6, Replies: []threadShape{{ID: 7}, {ID: 8}}},
+					}},
+				},
+			},
+			wantOrphans: []int{5},
+		},
+		{
+			name: "two cycles",
+			comments: []Comment{
+				testComment(1, 10, 2, 0),
+				testComment(2, 10, 1, 1),
+				testComment(3, 20, 4, 0),
+				testComment(4, 20, 3, 1),
+			},
+			want: map[int][]threadShape{
+				10: {{ID: 1, Orphaned: true, Replies: []threadShape{{ID: 2}}}},
+				20: {{ID: 3, Orphaned: true, Replies: []threadShape{{ID: 4}}}},
+			},
+			wantOrphans: []int{1, 3},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			threads, orphans := BuildCommentThreads(tc.comments)
+			got := make(map[int][]threadShape, len(threads))
+			for post, roots := range threads {
+				got[post] = shapes(roots)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("BuildCommentThreads() threads mismatch (-want +got):\n%s", diff)
+			}
+			var gotOrphans []int
+			for _, c := range orphans {
+				gotOrphans = append(gotOrphans, c.ID)
+			}
+			if diff := cmp.Diff(tc.wantOrphans, gotOrphans); diff != "" {
+				t.Errorf("BuildCommentThreads() orphans mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCommentThreadJSON(t *testing.T) {
+	threads, _ := BuildCommentThreads([]Comment{
+		testComment(1, 10, 99, 0),
+		testComment(2, 10, 1, 1),
+	})
+	b, err := json.Marshal(threads[10])
+	if err != nil {
+		t.Fatalf("Marshal() failed: %v", err)
+	}
+	var got []*CommentThread
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+	want := []threadShape{{ID: 1, Orphaned: true, Replies: []threadShape{{ID: 2}}}}
+	if diff := cmp.Diff(want, shapes(got)); diff != "" {
+		t.Errorf("round trip mismatch (-want +got):\n%s", diff)
+	}
+	if got[0].Parent != 99 || got[0].Post != 10 {
+		t.Errorf("round trip lost the comment fields: %+v", got[0].Comment)
+	}
+}
@@ -0,0 +1,97 @@
+package wordpress
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+)
+
+// Returns the timezone of the site, from its timezone_string if it names a
+// known location, otherwise from its gmt_offset. Sites without either are
+// in UTC.
+func (idx *Index) Location() *time.Location {
+	if idx.TimezoneString != "" {
+		if loc, err := time.LoadLocation(idx.TimezoneString); err == nil {
+			return loc
+		}
+	}
+	hours, err := strconv.ParseFloat(string(idx.GMTOffset), 64)
+	if err != nil || hours == 0 {
+		return time.UTC
+	}
+	return time.FixedZone("", int(hours*3600))
+}
+
+// Returns the timezone of the site, see Index.Location.
+func (c *Client) Location(ctx context.Context) (*time.Location, error) {
+	idx, err := c.GetIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return idx.Location(), nil
+}
+
+// Sets the typed times of the entities from the fetched content, resolving
+// local dates in the site's timezone if needed.
+func (c *Client) resolveTimes(ctx context.Context, content *SiteContent) {
+	if content.ResolveTimes(nil) {
+		return
+	}
+	loc, err := c.Location(ctx)
+	if err != nil {
+		log.Printf("failed to get the site's timezone, assuming UTC: %v", err)
+		loc = time.UTC
+	}
+	content.ResolveTimes(loc)
+}
+
+// Sets the unset typed times of the entities from their GMT dates, and if
+// there are none, from their local dates in loc. Returns false if local dates
+// were left unresolved because loc is nil.
+func (c *SiteContent) ResolveTimes(loc *time.Location) bool {
+	resolved := true
+	resolve := func(t *time.Time, gmt, local string) {
+		if !t.IsZero() {
+			return
+		}
+		if *t = parseDate(gmt, time.UTC); !t.IsZero() || local == "" {
+			return
+		}
+		if loc == nil {
+			resolved = false
+			return
+		}
+		*t = parseDate(local, loc)
+	}
+	for i := range c.Comments {
+		cm := &c.Comments[i]
+		resolve(&cm.DateTime, cm.DateGMT, cm.Date)
+	}
+	for i := range c.Media {
+		m := &c.Media[i]
+		resolve(&m.DateTime, m.DateGMT, m.Date)
+		resolve(&m.ModifiedTime, m.ModifiedGMT, m.Modified)
+	}
+	for i := range c.Pages {
+		p := &c.Pages[i]
+		resolve(&p.DateTime, p.DateGMT, p.Date)
+		resolve(&p.ModifiedTime, p.ModifiedGMT, p.Modified)
+	}
+	for i := range c.Posts {
+		p := &c.Posts[i]
+		resolve(&p.DateTime, p.DateGMT, p.Date)
+		resolve(&p.ModifiedTime, p.ModifiedGMT, p.Modified)
+	}
+	return resolved
+}
+
+// Parses a date in the format of the REST API, returning the zero time for
+// empty and invalid dates, e.g. the zero date of unpublished drafts.
+func parseDate(s string, loc *time.Location) time.Time {
+	t, err := time.ParseInLocation(wpDateLayout, s, loc)
+	if err != nil || t.Year() <= 1 {
+		return time.Time{}
+	}
+	return t.UTC()
+}
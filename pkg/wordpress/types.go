@@ -3,8 +3,68 @@ package wordpress
 import (
 	"encoding/json"
 	"fmt"
+	"html"
+	"strings"
+	"time"
 )
 
+const (
+	EntityCategories = "categories"
+	EntityComments   = "comments"
+	EntityMedia      = "media"
+	EntityPages      = "pages"
+	EntityPosts      = "posts"
+	EntityTags       = "tags"
+	EntityUsers      = "users"
+	// The discovered custom post types and taxonomies.
+	EntityCollections = "collections"
+
+	collectionsFile = EntityCollections + ".json"
+	// Lists the truncated entity lists of a snapshot, see
+	// SiteContent.Truncated.
+	TruncatedFile = "truncated.json"
+)
+
+// A field the API returns rendered as HTML. Raw is only set when using the
+// edit context.
+type RenderedField struct {
+	Raw       string `json:"raw,omitempty"`
+	Rendered  string `json:"rendered"`
+	Protected bool   `json:"protected,omitempty"`
+}
+
+// Prefers the raw field, and decodes the HTML entities of the rendered one
+// otherwise. For plain text fields, e.g. titles.
+func (f RenderedField) Text() string {
+	if f.Raw != "" {
+		return f.Raw
+	}
+	return html.UnescapeString(f.Rendered)
+}
+
+// The prefixes WordPress renders into the titles of private and password
+// protected posts. Only the English ones are known.
+const (
+	privateTitlePrefix   = "Private: "
+	protectedTitlePrefix = "Protected: "
+)
+
+// Returns the title as stored, without the prefix of private and password
+// protected posts the rendered title has.
+func plainTitle(title RenderedField, status string, protected bool) string {
+	if title.Raw != "" {
+		return title.Raw
+	}
+	t := title.Text()
+	if protected {
+		return strings.TrimPrefix(t, protectedTitlePrefix)
+	}
+	if status == "private" {
+		return strings.TrimPrefix(t, privateTitlePrefix)
+	}
+	return t
+}
+
 type Category struct {
 	ID   int    `json:"id"`
 	Link string `json:"link"`
@@ -26,63 +86,99 @@ type Category struct {
 			Href string `json:"href,omitempty"`
 		} `json:"about,omitempty"`
 	} `json:"_links,omitempty"`
+
+	// Original JSON, see Post.JSON.
+	JSON json.RawMessage `json:"-"`
 }
 
 type Comment struct {
-	ID        int    `json:"id"`
-	Post      int    `json:"post"`
-	Parent    int    `json:"parent"`
-	Author    int    `json:"author"`
-	AuthorURL string `json:"author_url"`
-	Date      string `json:"date"`
-	Content   struct {
-		Rendered string `json:"rendered"`
-	} `json:"content"`
-	Link string `json:"link"`
+	ID          int           `json:"id"`
+	Post        int           `json:"post"`
+	Parent      int           `json:"parent"`
+	Author      int           `json:"author"`
+	AuthorName  string        `json:"author_name"`
+	AuthorURL   string        `json:"author_url"`
+	AuthorEmail string        `json:"author_email,omitempty"`
+	AuthorIP    string        `json:"author_ip,omitempty"`
+	Status      string        `json:"status"`
+	Date        string        `json:"date"`
+	DateGMT     string        `json:"date_gmt"`
+	Content     RenderedField `json:"content"`
+	Link        string        `json:"link"`
+
+	// Parsed date, see Post.DateTime.
+	DateTime time.Time `json:"-"`
+
+	// Original JSON, see Post.JSON.
+	JSON json.RawMessage `json:"-"`
 }
 
 type Page struct {
-	ID       int    `json:"id"`
-	Date     string `json:"date"`
-	Link     string `json:"link"`
-	Modified string `json:"modified"`
-	Slug     string `json:"slug"`
-	Status   string `json:"status"`
-	Type     string `json:"type"`
-	Title    struct {
-		Rendered string `json:"rendered"`
-	} `json:"title"`
-	Content struct {
-		Rendered string `json:"rendered"`
-	} `json:"content"`
-	Excerpt struct {
-		Rendered string `json:"rendered"`
-	} `json:"excerpt"`
-	Author int `json:"author"`
-	Parent int `json:"parent"`
+	ID            int           `json:"id"`
+	Date          string        `json:"date"`
+	DateGMT       string        `json:"date_gmt"`
+	Link          string        `json:"link"`
+	Modified      string        `json:"modified"`
+	ModifiedGMT   string        `json:"modified_gmt"`
+	Slug          string        `json:"slug"`
+	Status        string        `json:"status"`
+	Type          string        `json:"type"`
+	Title         RenderedField `json:"title"`
+	Content       RenderedField `json:"content"`
+	Excerpt       RenderedField `json:"excerpt"`
+	Password      string        `json:"password,omitempty"`
+	Author        int           `json:"author"`
+	Parent        int           `json:"parent"`
+	FeaturedMedia int           `json:"featured_media"`
+
+	// Parsed dates, see Post.DateTime.
+	DateTime     time.Time `json:"-"`
+	ModifiedTime time.Time `json:"-"`
+
+	// Original JSON, see Post.JSON.
+	JSON json.RawMessage `json:"-"`
+}
+
+// See Post.PlainTitle.
+func (p Page) PlainTitle() string {
+	return plainTitle(p.Title, p.Status, p.Password != "" || p.Content.Protected)
 }
 
 type Post struct {
-	ID       int    `json:"id"`
-	Date     string `json:"date"`
-	Modified string `json:"modified"`
-	Slug     string `json:"slug"`
-	Status   string `json:"status"`
-	Type     string `json:"type"`
-	Link     string `json:"link"`
-	Title    struct {
-		Rendered string `json:"rendered"`
-	} `json:"title"`
-	Content struct {
-		Rendered string `json:"rendered"`
-	} `json:"content"`
-	Excerpt struct {
-		Rendered string `json:"rendered"`
-	} `json:"excerpt"`
-	Author        int   `json:"author"`
-	FeaturedMedia int   `json:"featured_media"`
-	Categories    []int `json:"categories"`
-	Tags          []int `json:"tags"`
+	ID            int           `json:"id"`
+	Date          string        `json:"date"`
+	DateGMT       string        `json:"date_gmt"`
+	Modified      string        `json:"modified"`
+	ModifiedGMT   string        `json:"modified_gmt"`
+	Slug          string        `json:"slug"`
+	Status        string        `json:"status"`
+	Type          string        `json:"type"`
+	Link          string        `json:"link"`
+	Title         RenderedField `json:"title"`
+	Content       RenderedField `json:"content"`
+	Excerpt       RenderedField `json:"excerpt"`
+	Password      string        `json:"password,omitempty"`
+	Author        int           `json:"author"`
+	FeaturedMedia int           `json:"featured_media"`
+	Categories    []int         `json:"categories"`
+	Tags          []int         `json:"tags"`
+
+	// Parsed from DateGMT and ModifiedGMT, or from Date and Modified in the
+	// site's timezone if there are no GMT dates, e.g. for drafts. They are
+	// not marshaled, see SiteContent.ResolveTimes.
+	DateTime     time.Time `json:"-"`
+	ModifiedTime time.Time `json:"-"`
+
+	// The entity as returned by the API, including the fields the struct
+	// does not model, e.g. meta or plugin fields. It is marshaled instead of
+	// the typed fields if set, so that snapshots are lossless.
+	JSON json.RawMessage `json:"-"`
+}
+
+// Returns the title without the prefix WordPress renders for private and
+// password protected posts.
+func (p Post) PlainTitle() string {
+	return plainTitle(p.Title, p.Status, p.Password != "" || p.Content.Protected)
 }
 
 type Tag struct {
@@ -93,6 +189,9 @@ type Tag struct {
 	Name        string `json:"name"`
 	Slug        string `json:"slug"`
 	Taxonomy    string `json:"taxonomy"`
+
+	// Original JSON, see Post.JSON.
+	JSON json.RawMessage `json:"-"`
 }
 
 type User struct {
@@ -103,15 +202,42 @@ type User struct {
 	Link        string            `json:"link"`
 	Slug        string            `json:"slug"`
 	AvatarURLs  map[string]string `json:"avatar_urls"`
+
+	// Only set when using the edit context.
+	Username       string   `json:"username,omitempty"`
+	FirstName      string   `json:"first_name,omitempty"`
+	LastName       string   `json:"last_name,omitempty"`
+	Email          string   `json:"email,omitempty"`
+	Nickname       string   `json:"nickname,omitempty"`
+	Locale         string   `json:"locale,omitempty"`
+	Roles          []string `json:"roles,omitempty"`
+	RegisteredDate string   `json:"registered_date,omitempty"`
+
+	// Original JSON, see Post.JSON.
+	JSON json.RawMessage `json:"-"`
 }
 
 type SiteContent struct {
 	Comments   []Comment
+	Media      []Media
 	Pages      []Page
 	Posts      []Post
 	Categories []Category
 	Tags       []Tag
 	Users      []User
+
+	// Custom post types and taxonomies, keyed by endpoint.
+	Collections []Collection
+	Extra       map[string][]json.RawMessage
+
+	// The entity lists cut short by the crawl budgets, stored as
+	// truncated.json. A snapshot without it is complete.
+	Truncated []Truncation
+
+	// Fingerprint of the site, stored as site.json.
+	Site *SiteInfo
+	// Set by the caller from Client.Verify, stored as verification.json.
+	Verification *Verification
 }
 
 func (c *SiteContent) Marshal() (map[string][]byte, error) {
@@ -119,6 +245,10 @@ func (c *SiteContent) Marshal() (map[string][]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal comments: %w", err)
 	}
+	media, err := json.Marshal(c.Media)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal media: %w", err)
+	}
 	pages, err := json.Marshal(c.Pages)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal pages: %w", err)
@@ -140,24 +270,115 @@ func (c *SiteContent) Marshal() (map[string][]byte, error) {
 		return nil, fmt.Errorf("failed to marshal users: %w", err)
 	}
 
-	return map[string][]byte{
-		"comments.json":   comments,
-		"pages.json":      pages,
-		"posts.json":      posts,
-		"categories.json": categories,
-		"tags.json":       tags,
-		"users.json":      users,
-	}, nil
-}
+	collections, err := json.Marshal(c.Collections)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal collections: %w", err)
+	}
 
-type APIErrorResponse struct {
-	Code    string `json:"code"`
-	Message string `json:"message,omitempty"`
-	Data    struct {
-		Status int `json:"status"`
-	} `json:"data,omitempty"`
+	files := map[string][]byte{
+		collectionsFile:            collections,
+		EntityComments + ".json":   comments,
+		EntityMedia + ".json":      media,
+		EntityPages + ".json":      pages,
+		EntityPosts + ".json":      posts,
+		EntityCategories + ".json": categories,
+		EntityTags + ".json":       tags,
+		EntityUsers + ".json":      users,
+	}
+	for _, col := range c.Collections {
+		entities, ok := c.Extra[col.Endpoint]
+		if !ok {
+			continue
+		}
+		b, err := json.Marshal(entities)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s: %w", col.Endpoint, err)
+		}
+		files[col.FileName()] = b
+	}
+	if len(c.Truncated) > 0 {
+		b, err := json.Marshal(c.Truncated)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal truncations: %w", err)
+		}
+		files[TruncatedFile] = b
+	}
+	if c.Site != nil {
+		b, err := json.Marshal(c.Site)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal site info: %w", err)
+		}
+		files[SiteFile] = b
+	}
+	if c.Verification != nil {
+		b, err := json.Marshal(c.Verification)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal verification: %w", err)
+		}
+		files[VerificationFile] = b
+	}
+	threads, err := c.marshalCommentThreads()
+	if err != nil {
+		return nil, err
+	}
+	for name, b := range threads {
+		files[name] = b
+	}
+	return files, nil
 }
 
-func (e *APIErrorResponse) IsInvalidPageNumber() bool {
-	return e.Code == "rest_post_invalid_page_number"
+// Reverses Marshal, also reading the entity types streamed by StreamAll.
+// Missing files are treated as empty entity lists.
+func UnmarshalSiteContent(files map[string][]byte) (*SiteContent, error) {
+	content := &SiteContent{}
+	for _, f := range []struct {
+		entity string
+		v      any
+	}{
+		{EntityComments, &content.Comments},
+		{EntityMedia, &content.Media},
+		{EntityPages, &content.Pages},
+		{EntityPosts, &content.Posts},
+		{EntityCategories, &content.Categories},
+		{EntityTags, &content.Tags},
+		{EntityUsers, &content.Users},
+	} {
+		if _, err := unmarshalEntities(files, f.entity, f.v); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %s: %w", f.entity, err)
+		}
+	}
+	if b, ok := files[collectionsFile]; ok {
+		if err := json.Unmarshal(b, &content.Collections); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal collections: %w", err)
+		}
+	}
+	if b, ok := files[TruncatedFile]; ok {
+		if err := json.Unmarshal(b, &content.Truncated); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal truncations: %w", err)
+		}
+	}
+	if b, ok := files[SiteFile]; ok {
+		content.Site = &SiteInfo{}
+		if err := json.Unmarshal(b, content.Site); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal site info: %w", err)
+		}
+	}
+	if b, ok := files[VerificationFile]; ok {
+		content.Verification = &Verification{}
+		if err := json.Unmarshal(b, content.Verification); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal verification: %w", err)
+		}
+	}
+	content.Extra = make(map[string][]json.RawMessage)
+	for _, col := range content.Collections {
+		var entities []json.RawMessage
+		ok, err := unmarshalEntities(files, strings.TrimSuffix(col.FileName(), ".json"), &entities)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %s: %w", col.Endpoint, err)
+		}
+		if ok {
+			content.Extra[col.Endpoint] = entities
+		}
+	}
+	return content, nil
 }
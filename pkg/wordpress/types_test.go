@@ -0,0 +1,53 @@
+package wordpress
+
+import "testing"
+
+func TestPlainTitle(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		post Post
+		want string
+	}{
+		{
+			name: "raw",
+			post: Post{Title: RenderedField{Raw: "Protected: Tom & Jerry", Rendered: "Protected: Tom &amp; Jerry"}, Password: "secret"},
+			want: "Protected: Tom & Jerry",
+		},
+		{
+			name: "rendered entities",
+			post: Post{Title: RenderedField{Rendered: "Tom &amp; Jerry&#8217;s &lt;3"}, Status: "publish"},
+			want: "Tom & Jerry’s <3",
+		},
+		{
+			name: "password",
+			post: Post{Title: RenderedField{Rendered: "Protected: Secret"}, Status: "publish", Password: "secret"},
+			want: "Secret",
+		},
+		{
+			name: "protected content",
+			post: Post{Title: RenderedField{Rendered: "Protected: Secret"}, Status: "publish", Content: RenderedField{Protected: true}},
+			want: "Secret",
+		},
+		{
+			name: "private",
+			post: Post{Title: RenderedField{Rendered: "Private: Notes"}, Status: "private"},
+			want: "Notes",
+		},
+		{
+			name: "private and protected",
+			post: Post{Title: RenderedField{Rendered: "Protected: Private: Notes"}, Status: "private", Password: "secret"},
+			want: "Private: Notes",
+		},
+		{
+			name: "prefix of a public post",
+			post: Post{Title: RenderedField{Rendered: "Private: A Novel"}, Status: "publish"},
+			want: "Private: A Novel",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.post.PlainTitle(); got != tc.want {
+				t.Errorf("PlainTitle() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package wordpress
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Stores the Verification of a snapshot, see SiteContent.Verification.
+	VerificationFile = "verification.json"
+
+	// The sitemap index of Yoast SEO and Rank Math. Both redirect the core
+	// sitemap to it.
+	seoSitemapPath = "/sitemap_index.xml"
+
+	postStatusPublish = "publish"
+)
+
+// Reconciles a snapshot with the site's sitemap and entity counts, to tell
+// whether the crawl captured everything.
+type Verification struct {
+	// The sitemap index the URLs were read from, empty if the site has none.
+	Sitemap     string `json:"sitemap,omitempty"`
+	SitemapURLs int    `json:"sitemap_urls"`
+	// Sitemap URLs no crawled entity links to.
+	MissingFromSnapshot []string `json:"missing_from_snapshot,omitempty"`
+	// Links of published entities the sitemap does not list, e.g. because
+	// an SEO plugin keeps them from being indexed.
+	MissingFromSitemap []string      `json:"missing_from_sitemap,omitempty"`
+	Counts             []EntityCount `json:"counts"`
+}
+
+type EntityCount struct {
+	Entity string `json:"entity"`
+	// The X-WP-Total of the collection.
+	Total   int `json:"total"`
+	Crawled int `json:"crawled"`
+	// Set if the entities could not be counted, Total is 0 then.
+	Error string `json:"error,omitempty"`
+}
+
+// Returns why the snapshot is incomplete, i.e. the sitemap URLs missing from
+// it and the entity types with fewer entities than the site reports or that
+// could not be counted. Links missing from the sitemap do not make it
+// incomplete.
+func (v *Verification) Problems() []string {
+	var problems []string
+	if n := len(v.MissingFromSnapshot); n > 0 {
+		problems = append(problems, fmt.Sprintf("%d sitemap URLs are missing from the snapshot", n))
+	}
+	for _, c := range v.Counts {
+		if c.Error != "" {
+			problems = append(problems, fmt.Sprintf("%s: could not be counted: %s", c.Entity, c.Error))
+			continue
+		}
+		if c.Crawled < c.Total {
+			problems = append(problems, fmt.Sprintf("%s: crawled %d of %d", c.Entity, c.Crawled, c.Total))
+		}
+	}
+	return problems
+}
+
+// Compares the content with the sitemap of the site, read from the core
+// wp-sitemap.xml or the index of an SEO plugin, and with the entity counts
+// of the REST API. Sites without a sitemap are only compared by counts. An
+// entity type that cannot be counted is recorded with the error, only a
+// canceled context fails the verification.
+func (c *Client) Verify(ctx context.Context, content *SiteContent) (*Verification, error) {
+	v := &Verification{}
+	for _, entity := range []struct {
+		name    string
+		crawled int
+	}{
+		{EntityCategories, len(content.Categories)},
+		{EntityComments, len(content.Comments)},
+		{EntityMedia, len(content.Media)},
+		{EntityPages, len(content.Pages)},
+		{EntityPosts, len(content.Posts)},
+		{EntityTags, len(content.Tags)},
+		{EntityUsers, len(content.Users)},
+	} {
+		if !c.fetches(entity.name) {
+			continue
+		}
+		count := EntityCount{Entity: entity.name, Crawled: entity.crawled}
+		total, err := c.Count(ctx, EntityEndpoint(entity.name), c.listOptions(entity.name))
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Printf("failed to count %s: %v", entity.name, err)
+			count.Error = err.Error()
+		}
+		count.Total = total
+		v.Counts = append(v.Counts, count)
+	}
+
+	var sitemap []SitemapURL
+	for _, p := range []string{wpSitemapPath, seoSitemapPath} {
+		urls, err := c.GetSitemap(ctx, c.baseURL+p)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Printf("no sitemap at %s: %v", p, err)
+			continue
+		}
+		v.Sitemap, sitemap = c.baseURL+p, urls
+		break
+	}
+	if v.Sitemap == "" {
+		return v, nil
+	}
+	v.SitemapURLs = len(sitemap)
+
+	crawled, published := content.links()
+	listed := make(map[string]bool, len(sitemap))
+	home := normalizeLink(c.baseURL)
+	for _, u := range sitemap {
+		link := normalizeLink(u.Loc)
+		listed[link] = true
+		// The home page is listed by some sitemaps but is no entity.
+		if !crawled[link] && link != home {
+			v.MissingFromSnapshot = append(v.MissingFromSnapshot, u.Loc)
+		}
+	}
+	for link, raw := range published {
+		if !listed[link] {
+			v.MissingFromSitemap = append(v.MissingFromSitemap, raw)
+		}
+	}
+	sort.Strings(v.MissingFromSnapshot)
+	sort.Strings(v.MissingFromSitemap)
+	return v, nil
+}
+
+// Returns the number of entities of a collection endpoint from its
+// X-WP-Total header, without fetching them.
+func (c *Client) Count(ctx context.Context, endpoint string, opts *ListOptions) (int, error) {
+	q := opts.query()
+	q.Set("per_page", "1")
+	q.Set("_fields", "id")
+	_, header, err := c.get(ctx, c.restURL(endpoint), q)
+	if err != nil {
+		return 0, err
+	}
+	total, err := strconv.Atoi(header.Get("X-WP-Total"))
+	if err != nil {
+		return 0, fmt.Errorf("no valid X-WP-Total header: %w", err)
+	}
+	return total, nil
+}
+
+// Returns the normalized links of all entities, and of the entities a
+// sitemap is expected to list, i.e. published posts and pages without a
+// password, terms in use and authors of published posts. The latter map to
+// the links as crawled.
+func (c *SiteContent) links() (map[string]bool, map[string]string) {
+	crawled := make(map[string]bool)
+	published := make(map[string]string)
+	add := func(link string, public bool) {
+		if link == "" {
+			return
+		}
+		crawled[normalizeLink(link)] = true
+		if public {
+			published[normalizeLink(link)] = link
+		}
+	}
+	authors := make(map[int]bool)
+	for _, p := range c.Posts {
+		public := p.Status == postStatusPublish && p.Password == ""
+		add(p.Link, public)
+		if public {
+			authors[p.Author] = true
+		}
+	}
+	for _, p := range c.Pages {
+		public := p.Status == postStatusPublish && p.Password == ""
+		add(p.Link, public)
+		if public {
+			authors[p.Author] = true
+		}
+	}
+	for _, t := range c.Categories {
+		add(t.Link, t.Count > 0)
+	}
+	for _, t := range c.Tags {
+		add(t.Link, t.Count > 0)
+	}
+	for _, u := range c.Users {
+		add(u.Link, authors[u.ID])
+	}
+	for _, entities := range c.Extra {
+		for _, raw := range entities {
+			var e struct {
+				Link   string `json:"link"`
+				Status string `json:"status"`
+				Count  *int   `json:"count"`
+			}
+			if json.Unmarshal(raw, &e) != nil {
+				continue
+			}
+			add(e.Link, (e.Status == "" || e.Status == postStatusPublish) && (e.Count == nil || *e.Count > 0))
+		}
+	}
+	return crawled, published
+}
+
+// Normalizes a link for comparison, ignoring the scheme, the case of the
+// host, a trailing slash and the fragment.
+func normalizeLink(link string) string {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return link
+	}
+	s := strings.ToLower(u.Host) + strings.TrimSuffix(u.EscapedPath(), "/")
+	if u.RawQuery != "" {
+		s += "?" + u.RawQuery
+	}
+	return s
+}
@@ -0,0 +1,135 @@
+package wordpress
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNormalizeLink(t *testing.T) {
+	for _, tc := range []struct {
+		link string
+		want string
+	}{
+		{"https://example.com/hello-world/", "example.com/hello-world"},
+		{"http://Example.COM/hello-world", "example.com/hello-world"},
+		{" https://example.com/hello-world/#comments ", "example.com/hello-world"},
+		{"https://example.com/?p=12", "example.com?p=12"},
+		{"https://example.com/", "example.com"},
+		{"https://example.com/caf%C3%A9/", "example.com/caf%C3%A9"},
+		{"https://example.com/café/", "example.com/caf%C3%A9"},
+	} {
+		if got := normalizeLink(tc.link); got != tc.want {
+			t.Errorf("normalizeLink(%q) = %q, want %q", tc.link, got, tc.want)
+		}
+	}
+}
+
+func TestLinks(t *testing.T) {
+	content := &SiteContent{
+		Posts: []Post{
+			{ID: 1, Link: "https://example.com/public/", Status: "publish", Author: 10},
+			{ID: 2, Link: "https://example.com/draft/", Status: "draft", Author: 11},
+			{ID: 3, Link: "https://example.com/protected/", Status: "publish", Password: "secret", Author: 12},
+			{ID: 4, Status: "publish"},
+		},
+		Pages: []Page{
+			{ID: 5, Link: "https://example.com/about/", Status: "publish", Author: 13},
+		},
+		Categories: []Category{
+			{ID: 6, Link: "https://example.com/category/news/", Count: 1},
+			{ID: 7, Link: "https://example.com/category/empty/"},
+		},
+		Tags: []Tag{
+			{ID: 8, Link: "https://example.com/tag/go/", Count: 2},
+		},
+		Users: []User{
+			{ID: 10, Link: "https://example.com/author/alice/"},
+			{ID: 11, Link: "https://example.com/author/bob/"},
+			{ID: 13, Link: "https://example.com/author/carol/"},
+		},
+		Extra: map[string][]json.RawMessage{
+			"/wp/v2/product": {
+				json.RawMessage(`{"link":"https://example.com/product/a/","status":"publish"}`),
+				json.RawMessage(`{"link":"https://example.com/product/b/","status":"private"}`),
+			},
+			"/wp/v2/genre": {
+				json.RawMessage(`{"link":"https://example.com/genre/jazz/","count":0}`),
+				json.RawMessage(`{"link":"https://example.com/genre/rock/","count":3}`),
+			},
+		},
+	}
+	crawled, published := content.links()
+
+	wantCrawled := map[string]bool{
+		"example.com/public":         true,
+		"example.com/draft":          true,
+		"example.com/protected":      true,
+		"example.com/about":          true,
+		"example.com/category/news":  true,
+		"example.com/category/empty": true,
+		"example.com/tag/go":         true,
+		"example.com/author/alice":   true,
+		"example.com/author/bob":     true,
+		"example.com/author/carol":   true,
+		"example.com/product/a":      true,
+		"example.com/product/b":      true,
+		"example.com/genre/jazz":     true,
+		"example.com/genre/rock":     true,
+	}
+	if diff := cmp.Diff(wantCrawled, crawled); diff != "" {
+		t.Errorf("links() crawled mismatch (-want +got):\n%s", diff)
+	}
+	wantPublished := map[string]string{
+		"example.com/public":        "https://example.com/public/",
+		"example.com/about":         "https://example.com/about/",
+		"example.com/category/news": "https://example.com/category/news/",
+		"example.com/tag/go":        "https://example.com/tag/go/",
+		"example.com/author/alice":  "https://example.com/author/alice/",
+		"example.com/author/carol":  "https://example.com/author/carol/",
+		"example.com/product/a":     "https://example.com/product/a/",
+		"example.com/genre/rock":    "https://example.com/genre/rock/",
+	}
+	if diff := cmp.Diff(wantPublished, published); diff != "" {
+		t.Errorf("links() published mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestVerificationProblems(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		v    Verification
+		want []string
+	}{
+		{
+			name: "complete",
+			v: Verification{
+				MissingFromSitemap: []string{"https://example.com/noindex/"},
+				Counts:             []EntityCount{{Entity: EntityPosts, Total: 2, Crawled: 2}},
+			},
+		},
+		{
+			name: "incomplete",
+			v: Verification{
+				MissingFromSnapshot: []string{"https://example.com/a/", "https://example.com/b/"},
+				Counts: []EntityCount{
+					{Entity: EntityPosts, Total: 3, Crawled: 2},
+					{Entity: EntityPages, Total: 1, Crawled: 1},
+					{Entity: EntityUsers, Crawled: 4, Error: "forbidden"},
+				},
+			},
+			want: []string{
+				"2 sitemap URLs are missing from the snapshot",
+				"posts: crawled 2 of 3",
+				"users: could not be counted: forbidden",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, tc.v.Problems()); diff != "" {
+				t.Errorf("Problems() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
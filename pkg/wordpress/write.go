@@ -0,0 +1,220 @@
+package wordpress
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+)
+
+// The write API accepts a subset of the fields returned by the read API,
+// with rendered fields like the title given as raw strings.
+
+type CategoryInput struct {
+	Name        string `json:"name,omitempty"`
+	Slug        string `json:"slug,omitempty"`
+	Description string `json:"description,omitempty"`
+	Parent      int    `json:"parent,omitempty"`
+}
+
+type TagInput struct {
+	Name        string `json:"name,omitempty"`
+	Slug        string `json:"slug,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type UserInput struct {
+	Username    string   `json:"username,omitempty"`
+	Name        string   `json:"name,omitempty"`
+	FirstName   string   `json:"first_name,omitempty"`
+	LastName    string   `json:"last_name,omitempty"`
+	Email       string   `json:"email,omitempty"`
+	URL         string   `json:"url,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Locale      string   `json:"locale,omitempty"`
+	Nickname    string   `json:"nickname,omitempty"`
+	Slug        string   `json:"slug,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	Password    string   `json:"password,omitempty"`
+}
+
+type PageInput struct {
+	Date          string `json:"date,omitempty"`
+	DateGMT       string `json:"date_gmt,omitempty"`
+	Slug          string `json:"slug,omitempty"`
+	Status        string `json:"status,omitempty"`
+	Password      string `json:"password,omitempty"`
+	Title         string `json:"title,omitempty"`
+	Content       string `json:"content,omitempty"`
+	Excerpt       string `json:"excerpt,omitempty"`
+	Author        int    `json:"author,omitempty"`
+	Parent        int    `json:"parent,omitempty"`
+	FeaturedMedia int    `json:"featured_media,omitempty"`
+}
+
+type PostInput struct {
+	Date          string `json:"date,omitempty"`
+	DateGMT       string `json:"date_gmt,omitempty"`
+	Slug          string `json:"slug,omitempty"`
+	Status        string `json:"status,omitempty"`
+	Password      string `json:"password,omitempty"`
+	Title         string `json:"title,omitempty"`
+	Content       string `json:"content,omitempty"`
+	Excerpt       string `json:"excerpt,omitempty"`
+	Author        int    `json:"author,omitempty"`
+	FeaturedMedia int    `json:"featured_media,omitempty"`
+	Categories    []int  `json:"categories,omitempty"`
+	Tags          []int  `json:"tags,omitempty"`
+}
+
+type CommentInput struct {
+	Post        int    `json:"post,omitempty"`
+	Parent      int    `json:"parent,omitempty"`
+	Author      int    `json:"author,omitempty"`
+	AuthorName  string `json:"author_name,omitempty"`
+	AuthorEmail string `json:"author_email,omitempty"`
+	AuthorURL   string `json:"author_url,omitempty"`
+	AuthorIP    string `json:"author_ip,omitempty"`
+	Content     string `json:"content,omitempty"`
+	Date        string `json:"date,omitempty"`
+	DateGMT     string `json:"date_gmt,omitempty"`
+	Status      string `json:"status,omitempty"`
+}
+
+type MediaInput struct {
+	Date        string `json:"date,omitempty"`
+	DateGMT     string `json:"date_gmt,omitempty"`
+	Slug        string `json:"slug,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Caption     string `json:"caption,omitempty"`
+	Description string `json:"description,omitempty"`
+	AltText     string `json:"alt_text,omitempty"`
+	Author      int    `json:"author,omitempty"`
+	Post        int    `json:"post,omitempty"`
+}
+
+func (c *Client) CreateCategory(ctx context.Context, in CategoryInput) (*Category, error) {
+	return Create[Category](ctx, c, categoriesPath, in)
+}
+
+func (c *Client) UpdateCategory(ctx context.Context, id int, in CategoryInput) (*Category, error) {
+	return Update[Category](ctx, c, categoriesPath, id, in)
+}
+
+func (c *Client) CreateTag(ctx context.Context, in TagInput) (*Tag, error) {
+	return Create[Tag](ctx, c, tagsPath, in)
+}
+
+func (c *Client) UpdateTag(ctx context.Context, id int, in TagInput) (*Tag, error) {
+	return Update[Tag](ctx, c, tagsPath, id, in)
+}
+
+func (c *Client) CreateUser(ctx context.Context, in UserInput) (*User, error) {
+	return Create[User](ctx, c, usersPath, in)
+}
+
+func (c *Client) UpdateUser(ctx context.Context, id int, in UserInput) (*User, error) {
+	return Update[User](ctx, c, usersPath, id, in)
+}
+
+func (c *Client) CreatePage(ctx context.Context, in PageInput) (*Page, error) {
+	return Create[Page](ctx, c, pagesPath, in)
+}
+
+func (c *Client) UpdatePage(ctx context.Context, id int, in PageInput) (*Page, error) {
+	return Update[Page](ctx, c, pagesPath, id, in)
+}
+
+func (c *Client) CreatePost(ctx context.Context, in PostInput) (*Post, error) {
+	return Create[Post](ctx, c, postsPath, in)
+}
+
+func (c *Client) UpdatePost(ctx context.Context, id int, in PostInput) (*Post, error) {
+	return Update[Post](ctx, c, postsPath, id, in)
+}
+
+func (c *Client) CreateComment(ctx context.Context, in CommentInput) (*Comment, error) {
+	return Create[Comment](ctx, c, commentsPath, in)
+}
+
+func (c *Client) UpdateComment(ctx context.Context, id int, in CommentInput) (*Comment, error) {
+	return Update[Comment](ctx, c, commentsPath, id, in)
+}
+
+// Uploads a media file. The body is streamed, so the request is not retried.
+func (c *Client) UploadMedia(ctx context.Context, fileName, contentType string, body io.Reader) (*Media, error) {
+	headers := http.Header{}
+	headers.Set("Content-Type", contentType)
+	headers.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	var m Media
+	if err := c.send(ctx, c.dl, http.MethodPost, mediaPath, headers, body, &m); err != nil {
+		return nil, fmt.Errorf("failed to upload media: %w", err)
+	}
+	return &m, nil
+}
+
+func (c *Client) UpdateMedia(ctx context.Context, id int, in MediaInput) (*Media, error) {
+	return Update[Media](ctx, c, mediaPath, id, in)
+}
+
+// Creates an entity in a collection endpoint, e.g. "/wp/v2/posts".
+func Create[T any](ctx context.Context, c *Client, endpoint string, in any) (*T, error) {
+	var out T
+	if err := c.sendJSON(ctx, http.MethodPost, endpoint, in, &out); err != nil {
+		return nil, fmt.Errorf("failed to create entity: %w", err)
+	}
+	return &out, nil
+}
+
+// Updates the entity with the given ID in a collection endpoint. Updates are
+// sent as PUT, so that they are retried unlike creates.
+func Update[T any](ctx context.Context, c *Client, endpoint string, id int, in any) (*T, error) {
+	var out T
+	if err := c.sendJSON(ctx, http.MethodPut, fmt.Sprintf("%s/%d", endpoint, id), in, &out); err != nil {
+		return nil, fmt.Errorf("failed to update entity %d: %w", id, err)
+	}
+	return &out, nil
+}
+
+func (c *Client) sendJSON(ctx context.Context, method, endpoint string, in, out any) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request body: %w", err)
+	}
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+	return c.send(ctx, c.cl, method, endpoint, headers, bytes.NewReader(b), out)
+}
+
+func (c *Client) send(ctx context.Context, cl *http.Client, method, endpoint string, headers http.Header, body io.Reader, out any) error {
+	req, err := c.newRequest(ctx, method, c.restURL(endpoint), body)
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	for k, vs := range headers {
+		req.Header[k] = vs
+	}
+
+	log.Printf("HTTP request: %s %s", req.Method, req.URL.String())
+
+	res, err := c.do(cl, req)
+	if err != nil {
+		return fmt.Errorf("failed to do HTTP request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return newAPIError(req, res)
+	}
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
+		return fmt.Errorf("got unexpected status code: %d", res.StatusCode)
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+// Package wpaudit checks what a WordPress site exposes to unauthenticated
+// visitors, e.g. its users, version and debug logs.
+package wpaudit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/ozansz/homelab-functions/pkg/wordpress"
+)
+
+type Severity string
+
+const (
+	SeverityInfo   Severity = "info"
+	SeverityLow    Severity = "low"
+	SeverityMedium Severity = "medium"
+	SeverityHigh   Severity = "high"
+)
+
+var severityRanks = map[Severity]int{
+	SeverityInfo:   0,
+	SeverityLow:    1,
+	SeverityMedium: 2,
+	SeverityHigh:   3,
+}
+
+func ParseSeverity(s string) (Severity, error) {
+	if _, ok := severityRanks[Severity(s)]; !ok {
+		return "", fmt.Errorf("unknown severity %q", s)
+	}
+	return Severity(s), nil
+}
+
+// Returns true if s is at least as severe as other.
+func (s Severity) AtLeast(other Severity) bool {
+	return severityRanks[s] >= severityRanks[other]
+}
+
+type Finding struct {
+	// Identifies the finding across reports, e.g. "debug-log" or
+	// "directory-listing:/wp-content/uploads/".
+	ID       string   `json:"id"`
+	Check    string   `json:"check"`
+	Severity Severity `json:"severity"`
+	Title    string   `json:"title"`
+	URL      string   `json:"url,omitempty"`
+	// What the site returned, e.g. the enumerated user slugs.
+	Evidence string `json:"evidence,omitempty"`
+	// Set by Report.Compare if the previous report does not have the
+	// finding.
+	New bool `json:"new,omitempty"`
+	// Set by Report.Compare if the finding is carried over from the previous
+	// report because its check failed.
+	Carried bool `json:"carried,omitempty"`
+}
+
+// A check that could not be completed, e.g. because the site was down. Its
+// findings are missing from the report, unless Report.Compare carries them
+// over from the previous one.
+type CheckError struct {
+	Check string `json:"check"`
+	Error string `json:"error"`
+}
+
+type Report struct {
+	Site      string    `json:"site"`
+	CreatedAt time.Time `json:"created_at"`
+	// Ordered by severity, most severe first.
+	Findings []Finding    `json:"findings"`
+	Errors   []CheckError `json:"errors,omitempty"`
+}
+
+type check struct {
+	name string
+	run  func(context.Context, *wordpress.Client) ([]Finding, error)
+}
+
+var checks = []check{
+	{checkUsers, checkUserEnumeration},
+	{checkAuthorRedirects, checkAuthorEnumeration},
+	{checkXMLRPC, checkXMLRPCEnabled},
+	{checkVersion, checkVersionDisclosure},
+	{checkReadme, checkReadmeExposed},
+	{checkDirectoryListing, checkDirectoryListings},
+	{checkDebugLog, checkDebugLogExposed},
+	{checkEndpoints, checkUnauthenticatedEndpoints},
+}
+
+// Runs every check against the site. The client must not have credentials,
+// so that the site is seen as an anonymous visitor sees it. A failing check
+// does not stop the others, its error is recorded in the report instead.
+func Audit(ctx context.Context, c *wordpress.Client, site string) (*Report, error) {
+	if c.IsAuthenticated() {
+		return nil, errors.New("the audit requires a client without credentials")
+	}
+	r := &Report{Site: site, CreatedAt: time.Now().UTC(), Findings: make([]Finding, 0)}
+	for _, ch := range checks {
+		findings, err := ch.run(ctx, c)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Printf("%s check failed: %v", ch.name, err)
+			r.Errors = append(r.Errors, CheckError{Check: ch.name, Error: err.Error()})
+			continue
+		}
+		for _, f := range findings {
+			f.Check = ch.name
+			r.Findings = append(r.Findings, f)
+		}
+	}
+	sortFindings(r.Findings)
+	return r, nil
+}
+
+func sortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		return severityRanks[findings[i].Severity] > severityRanks[findings[j].Severity]
+	})
+}
+
+// Marks the findings missing from the previous report as new. All findings
+// are new if there is no previous report. The previous findings of the
+// checks that failed are carried over, so that they are neither lost nor new
+// again once the check succeeds.
+func (r *Report) Compare(prev *Report) {
+	seen := make(map[string]bool)
+	if prev != nil {
+		for _, f := range prev.Findings {
+			seen[f.ID] = true
+		}
+	}
+	for i := range r.Findings {
+		r.Findings[i].New = !seen[r.Findings[i].ID]
+	}
+	if prev == nil || len(r.Errors) == 0 {
+		return
+	}
+	failed := make(map[string]bool, len(r.Errors))
+	for _, e := range r.Errors {
+		failed[e.Check] = true
+	}
+	for _, f := range prev.Findings {
+		if failed[f.Check] {
+			f.New, f.Carried = false, true
+			r.Findings = append(r.Findings, f)
+		}
+	}
+	sortFindings(r.Findings)
+}
+
+// Returns the new findings at least as severe as min.
+func (r *Report) NewFindings(min Severity) []Finding {
+	var findings []Finding
+	for _, f := range r.Findings {
+		if f.New && f.Severity.AtLeast(min) {
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
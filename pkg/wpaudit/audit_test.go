@@ -0,0 +1,130 @@
+package wpaudit
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSeverityAtLeast(t *testing.T) {
+	for _, tc := range []struct {
+		s, other Severity
+		want     bool
+	}{
+		{SeverityInfo, SeverityInfo, true},
+		{SeverityLow, SeverityInfo, true},
+		{SeverityInfo, SeverityLow, false},
+		{SeverityHigh, SeverityMedium, true},
+		{SeverityMedium, SeverityHigh, false},
+	} {
+		if got := tc.s.AtLeast(tc.other); got != tc.want {
+			t.Errorf("%s.AtLeast(%s) = %t, want %t", tc.s, tc.other, got, tc.want)
+		}
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	for _, tc := range []struct {
+		s       string
+		want    Severity
+		wantErr bool
+	}{
+		{s: "info", want: SeverityInfo},
+		{s: "high", want: SeverityHigh},
+		{s: "HIGH", wantErr: true},
+		{s: "", wantErr: true},
+	} {
+		got, err := ParseSeverity(tc.s)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ParseSeverity(%q) error = %v, want error: %t", tc.s, err, tc.wantErr)
+		}
+		if got != tc.want {
+			t.Errorf("ParseSeverity(%q) = %q, want %q", tc.s, got, tc.want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	debugLog := Finding{ID: "debug-log", Check: checkDebugLog, Severity: SeverityHigh}
+	readme := Finding{ID: "readme-exposed", Check: checkReadme, Severity: SeverityLow}
+	xmlrpc := Finding{ID: "xmlrpc-enabled", Check: checkXMLRPC, Severity: SeverityMedium}
+
+	for _, tc := range []struct {
+		name string
+		r    *Report
+		prev *Report
+		want []Finding
+	}{
+		{
+			name: "no previous report",
+			r:    &Report{Findings: []Finding{debugLog, readme}},
+			want: []Finding{
+				{ID: "debug-log", Check: checkDebugLog, Severity: SeverityHigh, New: true},
+				{ID: "readme-exposed", Check: checkReadme, Severity: SeverityLow, New: true},
+			},
+		},
+		{
+			name: "only missing findings are new",
+			r:    &Report{Findings: []Finding{debugLog, readme}},
+			prev: &Report{Findings: []Finding{readme, xmlrpc}},
+			want: []Finding{
+				{ID: "debug-log", Check: checkDebugLog, Severity: SeverityHigh, New: true},
+				{ID: "readme-exposed", Check: checkReadme, Severity: SeverityLow},
+			},
+		},
+		{
+			name: "findings of failed checks are carried over",
+			r: &Report{
+				Findings: []Finding{readme},
+				Errors:   []CheckError{{Check: checkDebugLog, Error: "site is down"}},
+			},
+			prev: &Report{Findings: []Finding{debugLog, readme, xmlrpc}},
+			want: []Finding{
+				{ID: "debug-log", Check: checkDebugLog, Severity: SeverityHigh, Carried: true},
+				{ID: "readme-exposed", Check: checkReadme, Severity: SeverityLow},
+			},
+		},
+		{
+			name: "failed check without previous report",
+			r: &Report{
+				Findings: []Finding{readme},
+				Errors:   []CheckError{{Check: checkDebugLog, Error: "site is down"}},
+			},
+			want: []Finding{
+				{ID: "readme-exposed", Check: checkReadme, Severity: SeverityLow, New: true},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.r.Compare(tc.prev)
+			if diff := cmp.Diff(tc.want, tc.r.Findings); diff != "" {
+				t.Errorf("Compare() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewFindings(t *testing.T) {
+	r := &Report{Findings: []Finding{
+		{ID: "a", Severity: SeverityHigh, New: true},
+		{ID: "b", Severity: SeverityHigh},
+		{ID: "c", Severity: SeverityLow, New: true},
+		{ID: "d", Severity: SeverityInfo, New: true},
+	}}
+	for _, tc := range []struct {
+		min  Severity
+		want []string
+	}{
+		{SeverityInfo, []string{"a", "c", "d"}},
+		{SeverityLow, []string{"a", "c"}},
+		{SeverityHigh, []string{"a"}},
+	} {
+		var got []string
+		for _, f := range r.NewFindings(tc.min) {
+			got = append(got, f.ID)
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("NewFindings(%s) mismatch (-want +got):\n%s", tc.min, diff)
+		}
+	}
+}
@@ -0,0 +1,355 @@
+package wpaudit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/ozansz/homelab-functions/pkg/wordpress"
+)
+
+const (
+	checkUsers            = "users"
+	checkAuthorRedirects  = "author-redirects"
+	checkXMLRPC           = "xmlrpc"
+	checkVersion          = "version"
+	checkReadme           = "readme"
+	checkDirectoryListing = "directory-listing"
+	checkDebugLog         = "debug-log"
+	checkEndpoints        = "endpoints"
+
+	restPrefix = "/wp-json"
+	usersPath  = restPrefix + "/wp/v2/users?per_page=100&_fields=id,slug"
+	xmlrpcPath = "/xmlrpc.php"
+	readmePath = "/readme.html"
+	debugPath  = "/wp-content/debug.log"
+
+	listMethodsCall = `<?xml version="1.0"?><methodCall><methodName>system.listMethods</methodName><params></params></methodCall>`
+
+	// The author IDs tried for redirects to author archives.
+	maxAuthorIDs = 3
+	// Limits the number of plugin routes requested by the endpoints check.
+	maxRouteProbes = 50
+	// Limits the number of items listed as evidence.
+	maxEvidenceItems = 10
+)
+
+var listedDirectories = []string{
+	"/wp-content/uploads/",
+	"/wp-content/plugins/",
+	"/wp-content/themes/",
+	"/wp-includes/",
+}
+
+// Core routes that require a logged in user unless something loosened their
+// permissions.
+var privateRoutes = []string{
+	"/wp/v2/settings",
+	"/wp/v2/plugins",
+	"/wp/v2/themes",
+	"/wp/v2/sidebars",
+	"/wp/v2/widgets",
+	"/wp/v2/widget-types",
+	"/wp/v2/menus",
+	"/wp/v2/menu-items",
+	"/wp/v2/menu-locations",
+	"/wp/v2/templates",
+	"/wp/v2/template-parts",
+	"/wp/v2/block-directory/search",
+}
+
+// Namespaces of WordPress itself, whose public routes are expected to be
+// public.
+var coreNamespaces = map[string]bool{
+	"wp/v2":              true,
+	"oembed/1.0":         true,
+	"wp-site-health/v1":  true,
+	"wp-block-editor/v1": true,
+}
+
+func checkUserEnumeration(ctx context.Context, c *wordpress.Client) ([]Finding, error) {
+	res, err := probe(ctx, c, http.MethodGet, usersPath, "")
+	if err != nil || res.StatusCode != http.StatusOK {
+		return nil, err
+	}
+	var users []struct {
+		Slug string `json:"slug"`
+	}
+	if err := json.Unmarshal(res.Body, &users); err != nil || len(users) == 0 {
+		// Not the REST API, e.g. a soft 404 page.
+		return nil, nil
+	}
+	slugs := make([]string, 0, len(users))
+	for _, u := range users {
+		slugs = append(slugs, u.Slug)
+	}
+	total := res.Header.Get("X-WP-Total")
+	if total == "" {
+		total = fmt.Sprint(len(users))
+	}
+	return []Finding{{
+		ID:       "user-enumeration-rest",
+		Severity: SeverityMedium,
+		Title:    "Users can be listed through the REST API",
+		URL:      res.URL,
+		Evidence: fmt.Sprintf("%s users: %s", total, evidenceList(slugs)),
+	}}, nil
+}
+
+func checkAuthorEnumeration(ctx context.Context, c *wordpress.Client) ([]Finding, error) {
+	var (
+		slugs []string
+		first string
+	)
+	for id := 1; id <= maxAuthorIDs; id++ {
+		res, err := probe(ctx, c, http.MethodGet, fmt.Sprintf("/?author=%d", id), "")
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode < 300 || res.StatusCode >= 400 {
+			continue
+		}
+		if slug := authorSlug(res.Header.Get("Location")); slug != "" {
+			slugs = append(slugs, slug)
+			if first == "" {
+				first = res.URL
+			}
+		}
+	}
+	if len(slugs) == 0 {
+		return nil, nil
+	}
+	return []Finding{{
+		ID:       "user-enumeration-author",
+		Severity: SeverityMedium,
+		Title:    "Author IDs redirect to archives named after the users",
+		URL:      first,
+		Evidence: evidenceList(slugs),
+	}}, nil
+}
+
+// Returns the user slug of an author archive URL, e.g. "admin" of
+// "https://example.com/author/admin/".
+func authorSlug(location string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := 0; i < len(parts)-1; i++ {
+		if parts[i] == "author" {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
+
+func checkXMLRPCEnabled(ctx context.Context, c *wordpress.Client) ([]Finding, error) {
+	res, err := probe(ctx, c, http.MethodPost, xmlrpcPath, listMethodsCall)
+	if err != nil || res.StatusCode != http.StatusOK || !bytes.Contains(res.Body, []byte("<methodResponse>")) {
+		return nil, err
+	}
+	var notable []string
+	for _, m := range []string{"system.multicall", "pingback.ping"} {
+		if bytes.Contains(res.Body, []byte("<string>"+m+"</string>")) {
+			notable = append(notable, m)
+		}
+	}
+	f := Finding{
+		ID:       "xmlrpc-enabled",
+		Severity: SeverityMedium,
+		Title:    "XML-RPC is enabled",
+		URL:      res.URL,
+	}
+	if len(notable) > 0 {
+		f.Evidence = "allows " + strings.Join(notable, ", ")
+	}
+	return []Finding{f}, nil
+}
+
+func checkVersionDisclosure(ctx context.Context, c *wordpress.Client) ([]Finding, error) {
+	// The version is detected from the home page, feed and readme even if
+	// the REST index is blocked.
+	info, err := c.GetSiteInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	if info.Version != "" {
+		findings = append(findings, Finding{
+			ID:       "version-disclosure",
+			Severity: SeverityLow,
+			Title:    "The WordPress version is disclosed",
+			Evidence: fmt.Sprintf("%s through the %s", info.Version, info.VersionSource),
+		})
+	}
+	for _, p := range info.Plugins {
+		if p.Version == "" {
+			continue
+		}
+		findings = append(findings, Finding{
+			ID:       "plugin-version-disclosure:" + p.Slug,
+			Severity: SeverityLow,
+			Title:    fmt.Sprintf("The version of the %s plugin is disclosed", p.Slug),
+			Evidence: fmt.Sprintf("%s through the %s", p.Version, strings.Join(p.Sources, ", ")),
+		})
+	}
+	return findings, nil
+}
+
+func checkReadmeExposed(ctx context.Context, c *wordpress.Client) ([]Finding, error) {
+	res, err := probe(ctx, c, http.MethodGet, readmePath, "")
+	if err != nil || res.StatusCode != http.StatusOK || !bytes.Contains(res.Body, []byte("WordPress")) {
+		return nil, err
+	}
+	return []Finding{{
+		ID:       "readme-exposed",
+		Severity: SeverityLow,
+		Title:    "readme.html is exposed",
+		URL:      res.URL,
+	}}, nil
+}
+
+func checkDirectoryListings(ctx context.Context, c *wordpress.Client) ([]Finding, error) {
+	var findings []Finding
+	for _, dir := range listedDirectories {
+		res, err := probe(ctx, c, http.MethodGet, dir, "")
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode != http.StatusOK || !bytes.Contains(res.Body, []byte("Index of /")) {
+			continue
+		}
+		findings = append(findings, Finding{
+			ID:       "directory-listing:" + dir,
+			Severity: SeverityMedium,
+			Title:    fmt.Sprintf("The directory listing of %s is enabled", dir),
+			URL:      res.URL,
+		})
+	}
+	return findings, nil
+}
+
+func checkDebugLogExposed(ctx context.Context, c *wordpress.Client) ([]Finding, error) {
+	res, err := probe(ctx, c, http.MethodGet, debugPath, "")
+	if err != nil || res.StatusCode != http.StatusOK || len(res.Body) == 0 {
+		return nil, err
+	}
+	// Sites that serve a page for missing files are not exposing a log.
+	if strings.HasPrefix(res.Header.Get("Content-Type"), "text/html") {
+		return nil, nil
+	}
+	return []Finding{{
+		ID:       "debug-log",
+		Severity: SeverityHigh,
+		Title:    "The debug log is exposed",
+		URL:      res.URL,
+		Evidence: fmt.Sprintf("%d bytes", len(res.Body)),
+	}}, nil
+}
+
+// Requests the private core routes and the routes of plugins without
+// credentials. Private core routes answering are severe, plugin routes are
+// reported for review, as many of them are public by design.
+func checkUnauthenticatedEndpoints(ctx context.Context, c *wordpress.Client) ([]Finding, error) {
+	idx, err := c.GetIndex(ctx)
+	if err != nil {
+		var apiErr *wordpress.APIErrorResponse
+		if errors.As(err, &apiErr) && !errors.Is(err, wordpress.ErrServerError) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	private := make(map[string]bool, len(privateRoutes))
+	for _, route := range privateRoutes {
+		private[route] = true
+	}
+	var routes []string
+	for route, r := range idx.Routes {
+		if private[route] {
+			routes = append(routes, route)
+			continue
+		}
+		// Namespace roots only list their routes, and routes with
+		// parameters cannot be requested without knowing them.
+		if coreNamespaces[r.Namespace] || route == "/"+r.Namespace || strings.Contains(route, "(?P<") || !hasMethod(r.Methods, http.MethodGet) {
+			continue
+		}
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+
+	var findings []Finding
+	probed := 0
+	for _, route := range routes {
+		if !private[route] {
+			if probed >= maxRouteProbes {
+				continue
+			}
+			probed++
+		}
+		res, err := probe(ctx, c, http.MethodGet, restPrefix+route, "")
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode != http.StatusOK || !json.Valid(res.Body) || isEmptyJSON(res.Body) {
+			continue
+		}
+		f := Finding{
+			ID:       "unauthenticated-endpoint:" + route,
+			Severity: SeverityInfo,
+			Title:    fmt.Sprintf("%s is reachable without authentication", route),
+			URL:      res.URL,
+		}
+		if private[route] {
+			f.Severity = SeverityHigh
+		}
+		findings = append(findings, f)
+	}
+	return findings, nil
+}
+
+func hasMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func isEmptyJSON(b []byte) bool {
+	b = bytes.TrimSpace(b)
+	return bytes.Equal(b, []byte("[]")) || bytes.Equal(b, []byte("{}")) || bytes.Equal(b, []byte("null"))
+}
+
+// Probes the site, treating 5xx responses as errors since a failing site
+// hides what it exposes.
+func probe(ctx context.Context, c *wordpress.Client, method, path, body string) (*wordpress.ProbeResponse, error) {
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	res, err := c.Probe(ctx, method, path, r)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= http.StatusInternalServerError {
+		return nil, fmt.Errorf("got unexpected status code: %d from %s", res.StatusCode, res.URL)
+	}
+	return res, nil
+}
+
+func evidenceList(items []string) string {
+	if len(items) <= maxEvidenceItems {
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(items[:maxEvidenceItems], ", "), len(items)-maxEvidenceItems)
+}
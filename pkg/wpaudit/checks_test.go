@@ -0,0 +1,69 @@
+package wpaudit
+
+import "testing"
+
+func TestAuthorSlug(t *testing.T) {
+	for _, tc := range []struct {
+		location string
+		want     string
+	}{
+		{"https://example.com/author/admin/", "admin"},
+		{"https://example.com/blog/author/jane-doe", "jane-doe"},
+		{"/author/editor/page/2/", "editor"},
+		{"https://example.com/author/", ""},
+		{"https://example.com/?author=1", ""},
+		{"", ""},
+	} {
+		if got := authorSlug(tc.location); got != tc.want {
+			t.Errorf("authorSlug(%q) = %q, want %q", tc.location, got, tc.want)
+		}
+	}
+}
+
+func TestIsEmptyJSON(t *testing.T) {
+	for _, tc := range []struct {
+		body string
+		want bool
+	}{
+		{"[]", true},
+		{" {}\n", true},
+		{"null", true},
+		{`[{"id":1}]`, false},
+		{`{"a":1}`, false},
+		{"", false},
+	} {
+		if got := isEmptyJSON([]byte(tc.body)); got != tc.want {
+			t.Errorf("isEmptyJSON(%q) = %t, want %t", tc.body, got, tc.want)
+		}
+	}
+}
+
+func TestEvidenceList(t *testing.T) {
+	for _, tc := range []struct {
+		items []string
+		want  string
+	}{
+		{nil, ""},
+		{[]string{"admin"}, "admin"},
+		{[]string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}, "a, b, c, d, e, f, g, h, i, j"},
+		{[]string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"}, "a, b, c, d, e, f, g, h, i, j and 2 more"},
+	} {
+		if got := evidenceList(tc.items); got != tc.want {
+			t.Errorf("evidenceList(%q) = %q, want %q", tc.items, got, tc.want)
+		}
+	}
+}
+
+func TestCell(t *testing.T) {
+	for _, tc := range []struct {
+		s, want string
+	}{
+		{"plain", "plain"},
+		{"a|b", `a\|b`},
+		{"two\nlines", "two lines"},
+	} {
+		if got := cell(tc.s); got != tc.want {
+			t.Errorf("cell(%q) = %q, want %q", tc.s, got, tc.want)
+		}
+	}
+}
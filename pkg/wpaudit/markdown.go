@@ -0,0 +1,57 @@
+package wpaudit
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Writes the report as a Markdown document, with a table of the findings.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Exposure audit of %s\n\n", r.Site)
+	fmt.Fprintf(&sb, "Created at %s.\n\n", r.CreatedAt.Format(time.RFC3339))
+
+	counts := make(map[Severity]int)
+	newCount := 0
+	for _, f := range r.Findings {
+		counts[f.Severity]++
+		if f.New {
+			newCount++
+		}
+	}
+	fmt.Fprintf(&sb, "%d findings, %d new: %d high, %d medium, %d low, %d info.\n\n",
+		len(r.Findings), newCount, counts[SeverityHigh], counts[SeverityMedium], counts[SeverityLow], counts[SeverityInfo])
+
+	if len(r.Findings) > 0 {
+		sb.WriteString("| Severity | Finding | URL | Evidence | New |\n")
+		sb.WriteString("| --- | --- | --- | --- | --- |\n")
+		for _, f := range r.Findings {
+			isNew := ""
+			if f.New {
+				isNew = "yes"
+			}
+			title := f.Title
+			if f.Carried {
+				title += " (from the previous report, the check failed)"
+			}
+			fmt.Fprintf(&sb, "| %s | %s | %s | %s | %s |\n", f.Severity, cell(title), cell(f.URL), cell(f.Evidence), isNew)
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(r.Errors) > 0 {
+		sb.WriteString("## Incomplete checks\n\n")
+		for _, e := range r.Errors {
+			fmt.Fprintf(&sb, "- %s: %s\n", e.Check, e.Error)
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// Escapes the characters that would break a table row.
+func cell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
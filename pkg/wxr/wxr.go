@@ -0,0 +1,407 @@
+// Package wxr writes WordPress eXtended RSS (WXR) 1.2 files, the format of
+// WordPress's own exporter and importer.
+package wxr
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ozansz/homelab-functions/pkg/wordpress"
+)
+
+const wxrDateLayout = "2006-01-02 15:04:05"
+
+type Options struct {
+	SiteTitle       string
+	SiteURL         string
+	SiteDescription string
+	Language        string
+	// Returns the URL the importer downloads the attachment from. Defaults
+	// to the media's source URL on the crawled site.
+	AttachmentURL func(m wordpress.Media) string
+}
+
+type author struct {
+	ID          int
+	Login       string
+	Email       string
+	DisplayName string
+	FirstName   string
+	LastName    string
+}
+
+type term struct {
+	ID          int
+	Slug        string
+	Name        string
+	Parent      string
+	Description string
+}
+
+type itemTerm struct {
+	Domain string
+	Slug   string
+	Name   string
+}
+
+type meta struct {
+	Key   string
+	Value string
+}
+
+type comment struct {
+	ID          int
+	Author      string
+	AuthorEmail string
+	AuthorURL   string
+	AuthorIP    string
+	Date        string
+	DateGMT     string
+	Content     string
+	Approved    string
+	Parent      int
+	UserID      int
+}
+
+type item struct {
+	ID            int
+	Title         string
+	Link          string
+	PubDate       string
+	Creator       string
+	GUID          string
+	Content       string
+	Excerpt       string
+	Date          string
+	DateGMT       string
+	Modified      string
+	ModifiedGMT   string
+	Slug          string
+	Status        string
+	Parent        int
+	Type          string
+	Password      string
+	AttachmentURL string
+	Terms         []itemTerm
+	Meta          []meta
+	Comments      []comment
+}
+
+type document struct {
+	Options    Options
+	PubDate    string
+	Authors    []author
+	Categories []term
+	Tags       []term
+	Items      []item
+}
+
+// Writes the content as a WXR file, with the site's users as authors, the
+// categories and tags as terms, and the posts, pages and media as items.
+// Comments are nested into the items they belong to.
+func Write(w io.Writer, content *wordpress.SiteContent, opts Options) error {
+	if opts.AttachmentURL == nil {
+		opts.AttachmentURL = func(m wordpress.Media) string { return m.SourceURL }
+	}
+	doc := document{
+		Options: opts,
+		PubDate: time.Now().UTC().Format(time.RFC1123Z),
+	}
+
+	logins := make(map[int]string, len(content.Users))
+	for _, u := range content.Users {
+		login := u.Username
+		if login == "" {
+			login = u.Slug
+		}
+		logins[u.ID] = login
+		doc.Authors = append(doc.Authors, author{
+			ID:          u.ID,
+			Login:       login,
+			Email:       u.Email,
+			DisplayName: u.Name,
+			FirstName:   u.FirstName,
+			LastName:    u.LastName,
+		})
+	}
+
+	categories := make(map[int]wordpress.Category, len(content.Categories))
+	for _, c := range content.Categories {
+		categories[c.ID] = c
+	}
+	for _, c := range content.Categories {
+		doc.Categories = append(doc.Categories, term{
+			ID:          c.ID,
+			Slug:        c.Slug,
+			Name:        html.UnescapeString(c.Name),
+			Parent:      categories[c.Parent].Slug,
+			Description: c.Description,
+		})
+	}
+	tags := make(map[int]wordpress.Tag, len(content.Tags))
+	for _, t := range content.Tags {
+		tags[t.ID] = t
+		doc.Tags = append(doc.Tags, term{
+			ID:          t.ID,
+			Slug:        t.Slug,
+			Name:        html.UnescapeString(t.Name),
+			Description: t.Description,
+		})
+	}
+
+	comments := make(map[int][]comment)
+	for _, c := range content.Comments {
+		comments[c.Post] = append(comments[c.Post], comment{
+			ID:          c.ID,
+			Author:      c.AuthorName,
+			AuthorEmail: c.AuthorEmail,
+			AuthorURL:   c.AuthorURL,
+			AuthorIP:    c.AuthorIP,
+			Date:        wxrDate(c.Date),
+			DateGMT:     wxrDate(c.DateGMT),
+			Content:     raw(c.Content),
+			Approved:    commentApproved(c.Status),
+			Parent:      c.Parent,
+			UserID:      c.Author,
+		})
+	}
+
+	for _, p := range content.Posts {
+		it := item{
+			ID:          p.ID,
+			Title:       p.PlainTitle(),
+			Link:        p.Link,
+			PubDate:     pubDate(p.DateTime),
+			Creator:     logins[p.Author],
+			GUID:        p.Link,
+			Content:     raw(p.Content),
+			Excerpt:     raw(p.Excerpt),
+			Date:        wxrDate(p.Date),
+			DateGMT:     wxrDate(p.DateGMT),
+			Modified:    wxrDate(p.Modified),
+			ModifiedGMT: wxrDate(p.ModifiedGMT),
+			Slug:        p.Slug,
+			Status:      p.Status,
+			Type:        orDefault(p.Type, "post"),
+			Password:    p.Password,
+			Comments:    comments[p.ID],
+		}
+		for _, id := range p.Categories {
+			if c, ok := categories[id]; ok {
+				it.Terms = append(it.Terms, itemTerm{Domain: "category", Slug: c.Slug, Name: html.UnescapeString(c.Name)})
+			}
+		}
+		for _, id := range p.Tags {
+			if t, ok := tags[id]; ok {
+				it.Terms = append(it.Terms, itemTerm{Domain: "post_tag", Slug: t.Slug, Name: html.UnescapeString(t.Name)})
+			}
+		}
+		if p.FeaturedMedia != 0 {
+			it.Meta = append(it.Meta, meta{Key: "_thumbnail_id", Value: fmt.Sprint(p.FeaturedMedia)})
+		}
+		doc.Items = append(doc.Items, it)
+	}
+
+	for _, p := range content.Pages {
+		it := item{
+			ID:          p.ID,
+			Title:       p.PlainTitle(),
+			Link:        p.Link,
+			PubDate:     pubDate(p.DateTime),
+			Creator:     logins[p.Author],
+			GUID:        p.Link,
+			Content:     raw(p.Content),
+			Excerpt:     raw(p.Excerpt),
+			Date:        wxrDate(p.Date),
+			DateGMT:     wxrDate(p.DateGMT),
+			Modified:    wxrDate(p.Modified),
+			ModifiedGMT: wxrDate(p.ModifiedGMT),
+			Slug:        p.Slug,
+			Status:      p.Status,
+			Parent:      p.Parent,
+			Type:        orDefault(p.Type, "page"),
+			Password:    p.Password,
+			Comments:    comments[p.ID],
+		}
+		if p.FeaturedMedia != 0 {
+			it.Meta = append(it.Meta, meta{Key: "_thumbnail_id", Value: fmt.Sprint(p.FeaturedMedia)})
+		}
+		doc.Items = append(doc.Items, it)
+	}
+
+	for _, m := range content.Media {
+		url := opts.AttachmentURL(m)
+		doc.Items = append(doc.Items, item{
+			ID:            m.ID,
+			Title:         m.Title.Text(),
+			Link:          m.Link,
+			PubDate:       pubDate(m.DateTime),
+			Creator:       logins[m.Author],
+			GUID:          url,
+			Content:       raw(m.Description),
+			Excerpt:       raw(m.Caption),
+			Date:          wxrDate(m.Date),
+			DateGMT:       wxrDate(m.DateGMT),
+			Modified:      wxrDate(m.Modified),
+			ModifiedGMT:   wxrDate(m.ModifiedGMT),
+			Slug:          m.Slug,
+			Status:        orDefault(m.Status, "inherit"),
+			Parent:        m.Post,
+			Type:          "attachment",
+			AttachmentURL: url,
+			Meta:          []meta{{Key: "_wp_attachment_image_alt", Value: m.AltText}},
+		})
+	}
+
+	return wxrTemplate.Execute(w, doc)
+}
+
+// Prefers the raw field, which is only captured with the edit context.
+func raw(f wordpress.RenderedField) string {
+	if f.Raw != "" {
+		return f.Raw
+	}
+	return f.Rendered
+}
+
+func wxrDate(d string) string {
+	return strings.Replace(d, "T", " ", 1)
+}
+
+func pubDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC1123Z)
+}
+
+// Anonymous crawls only see approved comments, which have no status.
+func commentApproved(status string) string {
+	switch status {
+	case "", "approved":
+		return "1"
+	case "hold":
+		return "0"
+	}
+	return status
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// Wraps the text in a CDATA section, splitting any "]]>" in it across two
+// sections like WordPress's exporter does.
+func cdata(s string) string {
+	return "<![CDATA[" + strings.ReplaceAll(s, "]]>", "]]]]><![CDATA[>") + "]]>"
+}
+
+var wxrTemplate = template.Must(template.New("wxr").Funcs(template.FuncMap{
+	"cdata": cdata,
+	"xml":   template.HTMLEscapeString,
+}).Parse(`<?xml version="1.0" encoding="UTF-8" ?>
+<rss version="2.0"
+	xmlns:excerpt="http://wordpress.org/export/1.2/excerpt/"
+	xmlns:content="http://purl.org/rss/1.0/modules/content/"
+	xmlns:wfw="http://wellformedweb.org/CommentAPI/"
+	xmlns:dc="http://purl.org/dc/elements/1.1/"
+	xmlns:wp="http://wordpress.org/export/1.2/"
+>
+<channel>
+	<title>{{xml .Options.SiteTitle}}</title>
+	<link>{{xml .Options.SiteURL}}</link>
+	<description>{{xml .Options.SiteDescription}}</description>
+	<pubDate>{{.PubDate}}</pubDate>
+	<language>{{xml .Options.Language}}</language>
+	<wp:wxr_version>1.2</wp:wxr_version>
+	<wp:base_site_url>{{xml .Options.SiteURL}}</wp:base_site_url>
+	<wp:base_blog_url>{{xml .Options.SiteURL}}</wp:base_blog_url>
+{{range .Authors}}
+	<wp:author>
+		<wp:author_id>{{.ID}}</wp:author_id>
+		<wp:author_login>{{cdata .Login}}</wp:author_login>
+		<wp:author_email>{{cdata .Email}}</wp:author_email>
+		<wp:author_display_name>{{cdata .DisplayName}}</wp:author_display_name>
+		<wp:author_first_name>{{cdata .FirstName}}</wp:author_first_name>
+		<wp:author_last_name>{{cdata .LastName}}</wp:author_last_name>
+	</wp:author>
+{{- end}}
+{{range .Categories}}
+	<wp:category>
+		<wp:term_id>{{.ID}}</wp:term_id>
+		<wp:category_nicename>{{cdata .Slug}}</wp:category_nicename>
+		<wp:category_parent>{{cdata .Parent}}</wp:category_parent>
+		<wp:cat_name>{{cdata .Name}}</wp:cat_name>
+		<wp:category_description>{{cdata .Description}}</wp:category_description>
+	</wp:category>
+{{- end}}
+{{range .Tags}}
+	<wp:tag>
+		<wp:term_id>{{.ID}}</wp:term_id>
+		<wp:tag_slug>{{cdata .Slug}}</wp:tag_slug>
+		<wp:tag_name>{{cdata .Name}}</wp:tag_name>
+		<wp:tag_description>{{cdata .Description}}</wp:tag_description>
+	</wp:tag>
+{{- end}}
+{{range .Items}}
+	<item>
+		<title>{{cdata .Title}}</title>
+		<link>{{xml .Link}}</link>
+		<pubDate>{{.PubDate}}</pubDate>
+		<dc:creator>{{cdata .Creator}}</dc:creator>
+		<guid isPermaLink="false">{{xml .GUID}}</guid>
+		<description></description>
+		<content:encoded>{{cdata .Content}}</content:encoded>
+		<excerpt:encoded>{{cdata .Excerpt}}</excerpt:encoded>
+		<wp:post_id>{{.ID}}</wp:post_id>
+		<wp:post_date>{{cdata .Date}}</wp:post_date>
+		<wp:post_date_gmt>{{cdata .DateGMT}}</wp:post_date_gmt>
+		<wp:post_modified>{{cdata .Modified}}</wp:post_modified>
+		<wp:post_modified_gmt>{{cdata .ModifiedGMT}}</wp:post_modified_gmt>
+		<wp:post_name>{{cdata .Slug}}</wp:post_name>
+		<wp:status>{{cdata .Status}}</wp:status>
+		<wp:post_parent>{{.Parent}}</wp:post_parent>
+		<wp:menu_order>0</wp:menu_order>
+		<wp:post_type>{{cdata .Type}}</wp:post_type>
+		<wp:post_password>{{cdata .Password}}</wp:post_password>
+		<wp:is_sticky>0</wp:is_sticky>
+		{{- if .AttachmentURL}}
+		<wp:attachment_url>{{cdata .AttachmentURL}}</wp:attachment_url>
+		{{- end}}
+		{{- range .Terms}}
+		<category domain="{{.Domain}}" nicename="{{xml .Slug}}">{{cdata .Name}}</category>
+		{{- end}}
+		{{- range .Meta}}
+		<wp:postmeta>
+			<wp:meta_key>{{cdata .Key}}</wp:meta_key>
+			<wp:meta_value>{{cdata .Value}}</wp:meta_value>
+		</wp:postmeta>
+		{{- end}}
+		{{- range .Comments}}
+		<wp:comment>
+			<wp:comment_id>{{.ID}}</wp:comment_id>
+			<wp:comment_author>{{cdata .Author}}</wp:comment_author>
+			<wp:comment_author_email>{{cdata .AuthorEmail}}</wp:comment_author_email>
+			<wp:comment_author_url>{{xml .AuthorURL}}</wp:comment_author_url>
+			<wp:comment_author_IP>{{cdata .AuthorIP}}</wp:comment_author_IP>
+			<wp:comment_date>{{cdata .Date}}</wp:comment_date>
+			<wp:comment_date_gmt>{{cdata .DateGMT}}</wp:comment_date_gmt>
+			<wp:comment_content>{{cdata .Content}}</wp:comment_content>
+			<wp:comment_approved>{{cdata .Approved}}</wp:comment_approved>
+			<wp:comment_type>{{cdata "comment"}}</wp:comment_type>
+			<wp:comment_parent>{{.Parent}}</wp:comment_parent>
+			<wp:comment_user_id>{{.UserID}}</wp:comment_user_id>
+		</wp:comment>
+		{{- end}}
+	</item>
+{{- end}}
+</channel>
+</rss>
+`))
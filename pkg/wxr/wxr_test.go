@@ -0,0 +1,88 @@
+package wxr
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/ozansz/homelab-functions/pkg/wordpress"
+)
+
+func TestCdata(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want string
+	}{
+		{"", "<![CDATA[]]>"},
+		{"<p>a & b</p>", "<![CDATA[<p>a & b</p>]]>"},
+		{"x]]>y", "<![CDATA[x]]]]><![CDATA[>y]]>"},
+		{"]]>]]>", "<![CDATA[]]]]><![CDATA[>]]]]><![CDATA[>]]>"},
+	} {
+		if got := cdata(tc.in); got != tc.want {
+			t.Errorf("cdata(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	content := &wordpress.SiteContent{
+		Categories: []wordpress.Category{{ID: 1, Name: "News &amp; Views", Slug: "news"}},
+		Posts: []wordpress.Post{
+			{
+				ID:         10,
+				Title:      wordpress.RenderedField{Rendered: "Tom &amp; Jerry&#8217;s"},
+				Content:    wordpress.RenderedField{Rendered: "<p>a[0]]>b</p>"},
+				Status:     "publish",
+				Link:       "https://example.com/?p=10&a=b",
+				Categories: []int{1},
+			},
+			{
+				ID:       11,
+				Title:    wordpress.RenderedField{Rendered: "Protected: Secret"},
+				Content:  wordpress.RenderedField{Protected: true},
+				Status:   "publish",
+				Password: "secret",
+			},
+		},
+		Pages: []wordpress.Page{
+			{ID: 12, Title: wordpress.RenderedField{Rendered: "Private: Notes"}, Status: "private"},
+		},
+		Media: []wordpress.Media{
+			{ID: 13, Title: wordpress.RenderedField{Rendered: "A &lt;b&gt; photo"}, SourceURL: "https://example.com/a.jpg"},
+		},
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, content, Options{SiteTitle: "Tom & Jerry", SiteURL: "https://example.com"}); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	type decodedItem struct {
+		Title    string `xml:"title"`
+		Link     string `xml:"link"`
+		Content  string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+		Category string `xml:"category"`
+	}
+	var got struct {
+		Channel struct {
+			Title string        `xml:"title"`
+			Items []decodedItem `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Write() wrote invalid XML: %v\n%s", err, buf.String())
+	}
+	if want := "Tom & Jerry"; got.Channel.Title != want {
+		t.Errorf("channel title = %q, want %q", got.Channel.Title, want)
+	}
+	want := []decodedItem{
+		{Title: "Tom & Jerry’s", Link: "https://example.com/?p=10&a=b", Content: "<p>a[0]]>b</p>", Category: "News & Views"},
+		{Title: "Secret"},
+		{Title: "Notes"},
+		{Title: "A <b> photo"},
+	}
+	if diff := cmp.Diff(want, got.Channel.Items); diff != "" {
+		t.Errorf("Write() items mismatch (-want +got):\n%s", diff)
+	}
+}